@@ -1,42 +1,182 @@
 package main
 
-// Uses an AI assistant to extract product name from search result page titles
+// Uses an AI assistant to extract product name from web search results
 
 import (
 	"cmp"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
-	"os"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
+	"unicode/utf8"
 
-	. "github.com/function61/gokit/builtin"
 	"github.com/joonas-fi/shopping-list-manager/pkg/openai"
+	"github.com/joonas-fi/shopping-list-manager/pkg/websearch"
 )
 
-func useAIAssistantToGuessProductDetailsFromSearchResults(ctx context.Context, searchResults []string, link string, logger *slog.Logger) (*productDetails, error) {
+func useAIAssistantToGuessProductDetailsFromSearchResults(ctx context.Context, searchResults []websearch.Result, link string, logger *slog.Logger) (*productDetails, error) {
 	withErr := func(err error) (*productDetails, error) {
 		return nil, fmt.Errorf("useAIAssistantToGuessProductDetailsFromSearchResults: %w", err)
 	}
 
-	aiProviderAPIKey := cmp.Or(os.Getenv("AI_PROVIDER_API_KEY"), os.Getenv("OPENAI_API_KEY"))
-	if err := ErrorIfUnset(aiProviderAPIKey == "", "aiProviderAPIKey"); err != nil {
+	conf, err := getAIConfig()
+	if err != nil {
 		return withErr(err)
 	}
 
-	prompt, answerMatchers := makePrompt(searchResults)
+	// covers downloading the image as well
+	ctx, cancel := conf.WithTimeout(ctx)
+	defer cancel()
+
+	image := ""
+	if conf.Vision { // titles can be ambiguous (store brands etc.) but a picture of the package usually isn't
+		image = productImageFromSearchResults(ctx, searchResults, logger)
+	}
 
-	res, err := openai.NewGoogle(aiProviderAPIKey).ChatCompletion(ctx, openai.SimpleChatCompletionReq(prompt, "gemini-2.5-flash"))
+	details, err := guessProductDetailsWithAI(ctx, conf.Client(), *conf, searchResults, image, link, logger)
 	if err != nil {
 		return withErr(err)
 	}
 
+	return details, nil
+}
+
+// the subset of `openai.Client` we need (so AI responses can be replayed in evaluation)
+type chatCompleter interface {
+	ChatCompletion(ctx context.Context, req openai.ChatCompletionReq) (*openai.ChatCompletionRes, error)
+}
+
+// image (URL or data URL) is optional and requires a vision-capable model
+func guessProductDetailsWithAI(ctx context.Context, ai chatCompleter, conf aiConfig, searchResults []websearch.Result, image string, link string, logger *slog.Logger) (*productDetails, error) {
+	ctx, cancel := conf.WithTimeout(ctx) // covers also the possible retry
+	defer cancel()
+
+	req := func() openai.ChatCompletionReq {
+		if image != "" {
+			return conf.ChatCompletionReqWithImage(makePrompt(searchResults, getLocale())+imagePromptAddendum, image)
+		} else {
+			return conf.ChatCompletionReq(makePrompt(searchResults, getLocale()))
+		}
+	}()
+	req.ResponseFormat = openai.ResponseFormatJSONSchema("product_details", productDetailsAnswerSchema())
+
+	answer, err := func() (*productDetailsAnswer, error) {
+		answer, errValidation := askProductDetails(ctx, ai, req, logger)
+		if errValidation == nil {
+			return answer, nil
+		}
+
+		var validationErr *answerValidationError
+		if !errors.As(errValidation, &validationErr) { // not worth retrying
+			return nil, errValidation
+		}
+
+		logger.Warn("AI agent answer failed validation; retrying once", "err", errValidation)
+
+		// give the model a chance to correct itself, now knowing what was wrong
+		req.Messages = append(req.Messages,
+			openai.ChatMessage{Role: "assistant", Content: validationErr.answer},
+			openai.ChatMessage{Role: "user", Content: fmt.Sprintf("Your answer was invalid: %s. Please answer again.", validationErr.reason)},
+		)
+
+		return askProductDetails(ctx, ai, req, logger)
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	details := productDetails{
+		Name:            answer.ProductName,
+		DisplayName:     answer.DisplayName,
+		Size:            answer.Size,
+		ProductType:     answer.ProductType,
+		ProductCategory: answer.ProductCategory,
+		Notes:           answer.Notes,
+		Confidence:      answer.Confidence,
+		NeedsReview:     answer.Confidence < reviewConfidenceThreshold || answer.ProductCategory == productCategories[0].Label,
+		Link:            link,
+		Source:          productSourceAI,
+		FirstScanned:    &now,
+		LastScanned:     &now,
+	}
+
+	logger.Debug("guessProductDetailsWithAI", "Name", details.Name, "ProductType", details.ProductType, "ProductCategory", details.ProductCategory, "Confidence", details.Confidence)
+
+	return &details, nil
+}
+
+const (
+	// products with AI-reported confidence below this are flagged for human review
+	reviewConfidenceThreshold = 0.7
+)
+
+// the structured answer we ask the AI agent to respond with
+type productDetailsAnswer struct {
+	ProductName     string  `json:"product_name"`
+	DisplayName     string  `json:"display_name"`
+	Size            string  `json:"size"`
+	ProductType     string  `json:"product_type"`
+	ProductCategory string  `json:"product_category"`
+	Notes           string  `json:"notes"`
+	Confidence      float64 `json:"confidence"`
+}
+
+func productDetailsAnswerSchema() map[string]any {
+	str := func(description string) map[string]any {
+		return map[string]any{"type": "string", "description": description}
+	}
+
+	productCategory := str(`If you're unsure choose "Other" and include in notes why you're unsure.`)
+	productCategory["enum"] = productCategoriesLabelsOnly
+
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"product_name":     str("Product name. Leave empty if you cannot resolve it."),
+			"display_name":     str(`Short name for a shopping list, without brand and size, for example "Kaurasämpylä".`),
+			"size":             str(`Package size or quantity, for example "480 g" or "6 x 0,33 l". Leave empty if unknown.`),
+			"product_type":     str(`Product type, for example just "Milk".`),
+			"product_category": productCategory,
+			"notes":            str("Additional notes if you have any, for example if you're unsure of some detail."),
+			"confidence": map[string]any{
+				"type":        "number",
+				"description": "How confident you are that the product name is correct, from 0.0 (pure guess) to 1.0 (certain).",
+				"minimum":     0,
+				"maximum":     1,
+			},
+		},
+		"required":             []string{"product_name", "display_name", "size", "product_type", "product_category", "notes", "confidence"},
+		"additionalProperties": false,
+	}
+}
+
+// AI agent answered, but the answer was not acceptable. the answer is retained so we can tell the
+// agent what was wrong with it.
+type answerValidationError struct {
+	answer string
+	reason string
+}
+
+func (a *answerValidationError) Error() string {
+	return "invalid answer: " + a.reason
+}
+
+func askProductDetails(ctx context.Context, ai chatCompleter, req openai.ChatCompletionReq, logger *slog.Logger) (*productDetailsAnswer, error) {
+	res, err := ai.ChatCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
 	switch len(res.Choices) {
 	case 0:
-		return withErr(errors.New("UNEXPECTED: 0 choices in response"))
+		return nil, errors.New("UNEXPECTED: 0 choices in response")
 	case 1:
 		// good
 	default:
@@ -46,81 +186,184 @@ func useAIAssistantToGuessProductDetailsFromSearchResults(ctx context.Context, s
 	bestChoice := res.Choices[0].Message // just assumption
 
 	if bestChoice.Refusal != nil {
-		return withErr(fmt.Errorf("AI agent refused, reason: %s", *bestChoice.Refusal))
+		return nil, fmt.Errorf("AI agent refused, reason: %s", *bestChoice.Refusal)
 	}
 
-	answer := bestChoice.Content
+	return parseProductDetailsAnswer(bestChoice.Content)
+}
 
-	resolve := func(re *regexp.Regexp) string {
-		match := re.FindStringSubmatch(answer)
-		if match == nil {
-			return ""
-		}
-		return match[1]
+func parseProductDetailsAnswer(answerJSON string) (*productDetailsAnswer, error) {
+	invalid := func(reason string) (*productDetailsAnswer, error) {
+		return nil, &answerValidationError{answer: answerJSON, reason: reason}
 	}
 
-	now := time.Now().UTC()
+	answer := &productDetailsAnswer{}
+	// some models wrap the JSON in a Markdown code fence even when asked for JSON
+	if err := json.Unmarshal([]byte(stripCodeFence(answerJSON)), answer); err != nil {
+		return invalid(fmt.Sprintf("not valid JSON: %v", err))
+	}
 
-	details := productDetails{
-		Name:            resolve(answerMatchers.ProductName),
-		ProductType:     resolve(answerMatchers.ProductType),
-		ProductCategory: resolve(answerMatchers.ProductCategory),
-		Notes:           resolve(answerMatchers.Notes),
-		Link:            link,
-		FirstScanned:    &now,
-		LastScanned:     &now,
+	answer.ProductName = strings.TrimSpace(answer.ProductName)
+	answer.ProductType = strings.TrimSpace(answer.ProductType)
+	answer.DisplayName = strings.TrimSpace(answer.DisplayName)
+	answer.Size = strings.TrimSpace(answer.Size)
+
+	if answer.Size == "" { // smaller models tend to forget this
+		answer.Size = parseProductSize(answer.ProductName)
 	}
 
-	if details.Name == "" {
-		return nil, fmt.Errorf("AI agent failed to resolve product name. notes: %s", resolve(answerMatchers.Notes))
+	if answer.ProductName == "" {
+		// not a validation error: the agent gave up and retrying would not help
+		return nil, fmt.Errorf("AI agent failed to resolve product name. notes: %s", answer.Notes)
 	}
 
-	logger.Debug("useAIAssistantToGuessProductDetailsFromSearchResults", "Name", details.Name, "ProductType", details.ProductType, "ProductCategory", details.ProductCategory)
+	if !slices.Contains(productCategoriesLabelsOnly, answer.ProductCategory) {
+		return invalid(fmt.Sprintf("product_category '%s' is not one of the allowed options", answer.ProductCategory))
+	}
 
-	return &details, nil
+	if answer.Confidence < 0 || answer.Confidence > 1 {
+		return invalid(fmt.Sprintf("confidence %f is not between 0.0 and 1.0", answer.Confidence))
+	}
+
+	return answer, nil
 }
 
-type promptAnswerMatchers struct {
-	ProductName     *regexp.Regexp
-	ProductType     *regexp.Regexp
-	ProductCategory *regexp.Regexp
-	Notes           *regexp.Regexp
+var codeFenceRe = regexp.MustCompile("(?s)^```[a-z]*\\n(.*?)\\n?```$")
+
+func stripCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if match := codeFenceRe.FindStringSubmatch(text); match != nil {
+		return match[1]
+	}
+	return text
 }
 
-func makePrompt(searchResults []string) (string, promptAnswerMatchers) {
-	promptTemplate := `I have list of web search result page titles (one per line), try to guess what is the product name (usually it's a grocery store item, but not always):
+func makePrompt(searchResults []websearch.Result, loc locale) string {
+	promptTemplate := `I have list of web search results (most informative first), try to guess what is the product name (usually it's a grocery store item, but not always):
 
 CODEFENCE
-%s
+%[1]s
 CODEFENCE
 
-If search results are in multiple languages, prefer Finnish and then English.
+The "product" lines are structured data that the web pages publish about themselves, so they are usually more accurate than the titles.
 
-This will be variable *ProductName*. It may or may not be in Finnish.
+If search results are in multiple languages, prefer %[2]s.
 
-I want also to resolve product type (*ProductType*) and product category (*ProductCategory*) for the product name. Product type example is just "Milk" and product category is one of these rigid options:
+This will be *product_name*. It may or may not be in %[3]s.
 
-- %s
+Also give a short name that fits on a shopping list (*display_name*): drop the brand, size and marketing words, for example "Kaurasämpylä" instead of "Vaasan Voimallus Kaurasämpylä kaurainen sämpylä 480 g 8 kpl". Tell the package size or quantity separately (*size*), like "480 g 8 kpl", or leave it empty if unknown.
 
-Please respond succinctly in this format:
+I want also to resolve product type (*product_type*) and product category (*product_category*) for the product name. Product type example is just "Milk" (but answer it in %[3]s) and product category is one of these rigid options:
 
-CODEFENCE
-Product name: <ProductName>
-Product type: <ProductType>
-Product category: <ProductCategory>
-Notes: <notes if you have any additional notes, for example if you're unsure of some detail>
-CODEFENCE
+- %[4]s
+
+Please respond in JSON. For the category if you're unsure choose "Other" and include in notes why you're unsure.
 
-For the category if you're unsure choose "Other" and include in notes why you're unsure.
+Also tell how confident you are of the product name (*confidence*). Be honest: a guess based on vague or conflicting search results should get a low confidence.
 `
 
 	return fmt.Sprintf(strings.ReplaceAll(promptTemplate, "CODEFENCE", "```"),
-			strings.Join(searchResults, "\n"),
-			strings.Join(productCategoriesLabelsOnly, "\n- "),
-		), promptAnswerMatchers{
-			ProductName:     regexp.MustCompile(`Product name: ([^\n]+)`),
-			ProductType:     regexp.MustCompile(`Product type: ([^\n]+)`),
-			ProductCategory: regexp.MustCompile(`Product category: ([^\n]+)`),
-			Notes:           regexp.MustCompile(`Notes: ([^\n]+)`),
+		makeSearchResultsDigest(searchResults, promptDigestTokenBudget),
+		preferredLanguages(loc),
+		loc.LanguageName(),
+		strings.Join(productCategoriesLabelsOnly, "\n- "),
+	)
+}
+
+// "Finnish and then English"
+func preferredLanguages(loc locale) string {
+	english := messageCatalogs["en"]["language_name"]
+
+	if language := loc.LanguageName(); language != english {
+		return language + " and then " + english
+	} else {
+		return english
+	}
+}
+
+const (
+	// the search results are the bulk of the prompt. keep its size in check so costs (and dilution
+	// of the model's attention) don't grow with verbose search results.
+	promptDigestTokenBudget = 1500
+
+	promptDigestSnippetMaxLen = 200 // [runes]
+)
+
+// compact digest of search results, one block per result:
+//
+//	[1] <title>
+//	    snippet: <snippet>
+//	    product: <name> (brand: <brand>)
+//	    product description: <description>
+func makeSearchResultsDigest(searchResults []websearch.Result, tokenBudget int) string {
+	ranked := slices.Clone(searchResults)
+	// structured product data is the most accurate source of product names, so those are worth the most
+	slices.SortStableFunc(ranked, func(a, b websearch.Result) int {
+		return cmp.Compare(searchResultInformativeness(b), searchResultInformativeness(a))
+	})
+
+	blocks := []string{}
+	tokensUsed := 0
+
+	for idx, result := range ranked {
+		lines := []string{fmt.Sprintf("[%d] %s", idx+1, result.Title)}
+
+		if snippet := truncateRunes(oneLine(result.Snippet), promptDigestSnippetMaxLen); snippet != "" {
+			lines = append(lines, "    snippet: "+snippet)
 		}
+
+		if p := result.Product; p != nil {
+			if p.Name != "" {
+				if p.Brand != "" {
+					lines = append(lines, fmt.Sprintf("    product: %s (brand: %s)", oneLine(p.Name), oneLine(p.Brand)))
+				} else {
+					lines = append(lines, "    product: "+oneLine(p.Name))
+				}
+			}
+
+			if description := truncateRunes(oneLine(p.Description), promptDigestSnippetMaxLen); description != "" {
+				lines = append(lines, "    product description: "+description)
+			}
+		}
+
+		block := strings.Join(lines, "\n")
+
+		blockTokens := estimateTokens(block)
+		if tokensUsed+blockTokens > tokenBudget && len(blocks) > 0 { // always include at least one
+			break
+		}
+		tokensUsed += blockTokens
+
+		blocks = append(blocks, block)
+	}
+
+	return strings.Join(blocks, "\n")
+}
+
+func searchResultInformativeness(result websearch.Result) int {
+	score := 0
+	if result.Product != nil && result.Product.Name != "" {
+		score += 2
+	}
+	if result.Snippet != "" {
+		score++
+	}
+	return score
+}
+
+// rough heuristic (for European languages) is that a token is ~4 characters
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+func oneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func truncateRunes(text string, maxLen int) string {
+	runes := []rune(text)
+	if len(runes) <= maxLen {
+		return text
+	}
+	return string(runes[:maxLen]) + "…"
 }
@@ -5,13 +5,69 @@ import (
 	"testing"
 
 	"github.com/function61/gokit/testing/assert"
+	"github.com/joonas-fi/shopping-list-manager/pkg/websearch"
 )
 
+var finnish = locale{"fi", messageCatalogs["fi"]}
+
 //go:embed testdata/expected_prompt.txt
 var expectedPrompt string
 
+//go:embed testdata/expected_prompt_structured.txt
+var expectedPromptStructured string
+
 func TestMakePrompt(t *testing.T) {
-	prompt, _ := makePrompt([]string{"Tacokastike", "Tacokastike 100 g"})
+	prompt := makePrompt([]websearch.Result{
+		{Title: "Tacokastike"},
+		{Title: "Tacokastike 100 g"},
+	}, finnish)
 
 	assert.Equal(t, prompt, expectedPrompt)
 }
+
+func TestMakePromptStructured(t *testing.T) {
+	prompt := makePrompt([]websearch.Result{
+		{Title: "6408180733659 - Hakutulokset"},
+		{
+			Title:   "Kaurasämpylä | K-Ruoka",
+			Snippet: "Vaasan Voimallus Kaurasämpylä on  kaurainen\nsämpylä.",
+		},
+		{
+			Title: "Vaasan Voimallus Kaurasämpylä 480 g | S-kaupat",
+			Product: &websearch.Product{
+				Name:        "Vaasan Voimallus Kaurasämpylä kaurainen sämpylä 480 g 8 kpl",
+				Brand:       "Vaasan",
+				Description: "Kaurainen sämpylä",
+			},
+		},
+	}, finnish)
+
+	assert.Equal(t, prompt, expectedPromptStructured)
+}
+
+func TestMakeSearchResultsDigestTokenBudget(t *testing.T) {
+	results := []websearch.Result{
+		{Title: "First result that is quite long"},
+		{Title: "Second"},
+	}
+
+	assert.Equal(t, makeSearchResultsDigest(results, 1), "[1] First result that is quite long")
+	assert.Equal(t, makeSearchResultsDigest(results, 100), "[1] First result that is quite long\n[2] Second")
+}
+
+func TestParseProductDetailsAnswer(t *testing.T) {
+	parse := func(answerJSON string) string {
+		answer, err := parseProductDetailsAnswer(answerJSON)
+		if err != nil {
+			return err.Error()
+		}
+		return answer.ProductName + " | " + answer.ProductType + " | " + answer.ProductCategory
+	}
+
+	assert.Equal(t, parse(`{"product_name": "Tacokastike 100 g", "product_type": "Taco sauce", "product_category": "Condiments & Sauces", "notes": "", "confidence": 0.9}`), "Tacokastike 100 g | Taco sauce | Condiments & Sauces")
+	assert.Equal(t, parse("```json\n{\"product_name\": \"Maito\", \"product_type\": \"Milk\", \"product_category\": \"Dairy & Eggs\", \"notes\": \"\"}\n```"), "Maito | Milk | Dairy & Eggs")
+	assert.Equal(t, parse(`{"product_name": "Maito", "product_type": "Milk", "product_category": "Dairy", "notes": ""}`), "invalid answer: product_category 'Dairy' is not one of the allowed options")
+	assert.Equal(t, parse(`{"product_name": "", "product_type": "", "product_category": "Other", "notes": "no idea"}`), "AI agent failed to resolve product name. notes: no idea")
+	assert.Equal(t, parse(`{"product_name": "Maito", "product_type": "Milk", "product_category": "Dairy & Eggs", "notes": "", "confidence": 85}`), "invalid answer: confidence 85.000000 is not between 0.0 and 1.0")
+	assert.Equal(t, parse(`Product name: **Maito**`), "invalid answer: not valid JSON: invalid character 'P' looking for beginning of value")
+}
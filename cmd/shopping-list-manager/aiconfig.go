@@ -0,0 +1,141 @@
+package main
+
+// Configuration of the AI provider (any OpenAI-compatible API) used for resolving product details
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	. "github.com/function61/gokit/builtin"
+	"github.com/joonas-fi/shopping-list-manager/pkg/openai"
+)
+
+type aiConfig struct {
+	BaseURL     string
+	APIKey      string // can be empty for local servers
+	Model       string
+	Temperature *float64 // nil = provider's default
+	Timeout     time.Duration
+	Vision      bool // model accepts images
+	Price       aiModelPrice
+}
+
+type aiProviderPreset struct {
+	baseURL        string
+	defaultModel   string
+	apiKeyRequired bool
+}
+
+var aiProviderPresets = map[string]aiProviderPreset{
+	"google": {"https://generativelanguage.googleapis.com/v1beta/openai/", "gemini-2.5-flash", true},
+	"openai": {"https://api.openai.com/v1/", openai.ModelGPT4o, true},
+	"ollama": {"http://localhost:11434/v1/", "", false},
+}
+
+func getAIConfig() (*aiConfig, error) {
+	withErr := func(err error) (*aiConfig, error) { return nil, fmt.Errorf("getAIConfig: %w", err) }
+
+	providerName := cmp.Or(os.Getenv("AI_PROVIDER"), "google")
+
+	preset, found := aiProviderPresets[providerName]
+	if !found {
+		return withErr(fmt.Errorf("unsupported AI_PROVIDER: %s", providerName))
+	}
+
+	// base URL override enables any OpenAI-compatible server (llama.cpp etc.)
+	baseURLOverride := os.Getenv("AI_PROVIDER_BASEURL")
+
+	conf := &aiConfig{
+		BaseURL: cmp.Or(baseURLOverride, preset.baseURL),
+		APIKey:  cmp.Or(os.Getenv("AI_PROVIDER_API_KEY"), os.Getenv("OPENAI_API_KEY")),
+		Model:   cmp.Or(os.Getenv("AI_MODEL"), preset.defaultModel),
+		Timeout: 60 * time.Second,
+	}
+
+	if err := ErrorIfUnset(conf.Model == "", "AI_MODEL"); err != nil {
+		return withErr(err)
+	}
+
+	if err := ErrorIfUnset(preset.apiKeyRequired && baseURLOverride == "" && conf.APIKey == "", "AI_PROVIDER_API_KEY"); err != nil {
+		return withErr(err)
+	}
+
+	if temperatureStr := os.Getenv("AI_TEMPERATURE"); temperatureStr != "" {
+		temperature, err := strconv.ParseFloat(temperatureStr, 64)
+		if err != nil {
+			return withErr(fmt.Errorf("AI_TEMPERATURE: %w", err))
+		}
+		conf.Temperature = &temperature
+	}
+
+	// opt-in since images add latency (downloading them) and cost (image tokens)
+	if visionStr := os.Getenv("AI_VISION"); visionStr != "" {
+		vision, err := strconv.ParseBool(visionStr)
+		if err != nil {
+			return withErr(fmt.Errorf("AI_VISION: %w", err))
+		}
+		conf.Vision = vision
+	}
+
+	price, err := aiModelPriceFor(conf.Model)
+	if err != nil {
+		return withErr(err)
+	}
+	conf.Price = price
+
+	spent, err := usageTotal(usageMonth(time.Now()), usageProviderAIPrefix)
+	if err != nil {
+		return withErr(err)
+	}
+
+	if err := applyAIBudget(conf, spent.EstimatedCost); err != nil {
+		return withErr(err)
+	}
+
+	if timeoutStr := os.Getenv("AI_TIMEOUT"); timeoutStr != "" {
+		timeout, err := time.ParseDuration(timeoutStr)
+		if err != nil {
+			return withErr(fmt.Errorf("AI_TIMEOUT: %w", err))
+		}
+		conf.Timeout = timeout
+	}
+
+	return conf, nil
+}
+
+// usage of the client is recorded for cost accounting
+func (a aiConfig) Client() chatCompleter {
+	return meteredChatCompleter{
+		inner: openai.NewWithBaseURL(a.BaseURL, a.APIKey),
+		model: a.Model,
+		price: a.Price,
+	}
+}
+
+func (a aiConfig) WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, a.Timeout)
+}
+
+// request with prompt, using the configured model and temperature
+func (a aiConfig) ChatCompletionReq(prompt string) openai.ChatCompletionReq {
+	req := openai.SimpleChatCompletionReq(prompt, a.Model)
+	req.Temperature = a.Temperature
+	return req
+}
+
+// like `ChatCompletionReq()` but with an image attached to the prompt. image is an URL or a data URL.
+func (a aiConfig) ChatCompletionReqWithImage(prompt string, image string) openai.ChatCompletionReq {
+	req := a.ChatCompletionReq(prompt)
+
+	userMessage := &req.Messages[len(req.Messages)-1]
+	userMessage.ContentParts = []openai.ContentPart{
+		openai.TextPart(userMessage.Content),
+		openai.ImagePart(image),
+	}
+
+	return req
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/function61/gokit/testing/assert"
+)
+
+func TestGetAIConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		env    map[string]string
+		expect string
+	}{
+		{
+			"defaults to Google",
+			map[string]string{"AI_PROVIDER_API_KEY": "key"},
+			"https://generativelanguage.googleapis.com/v1beta/openai/ gemini-2.5-flash key temperature=<nil> timeout=1m0s vision=false",
+		},
+		{
+			"OpenAI with its legacy API key ENV",
+			map[string]string{"AI_PROVIDER": "openai", "OPENAI_API_KEY": "key"},
+			"https://api.openai.com/v1/ gpt-4o key temperature=<nil> timeout=1m0s vision=false",
+		},
+		{
+			"Ollama needs no API key but needs a model",
+			map[string]string{"AI_PROVIDER": "ollama", "AI_MODEL": "llama3.1:8b"},
+			"http://localhost:11434/v1/ llama3.1:8b  temperature=<nil> timeout=1m0s vision=false",
+		},
+		{
+			"Ollama without a model",
+			map[string]string{"AI_PROVIDER": "ollama"},
+			"getAIConfig: 'AI_MODEL' is required",
+		},
+		{
+			"API key required for hosted providers",
+			map[string]string{"AI_PROVIDER": "openai"},
+			"getAIConfig: 'AI_PROVIDER_API_KEY' is required",
+		},
+		{
+			"base URL override doesn't need an API key",
+			map[string]string{"AI_PROVIDER": "openai", "AI_PROVIDER_BASEURL": "http://localhost:8080/v1/", "AI_MODEL": "qwen2.5"},
+			"http://localhost:8080/v1/ qwen2.5  temperature=<nil> timeout=1m0s vision=false",
+		},
+		{
+			"overrides",
+			map[string]string{"AI_PROVIDER_API_KEY": "key", "AI_MODEL": "gemini-2.5-pro", "AI_TEMPERATURE": "0.2", "AI_TIMEOUT": "15s", "AI_VISION": "true"},
+			"https://generativelanguage.googleapis.com/v1beta/openai/ gemini-2.5-pro key temperature=0.2 timeout=15s vision=true",
+		},
+		{
+			"unsupported provider",
+			map[string]string{"AI_PROVIDER": "skynet"},
+			"getAIConfig: unsupported AI_PROVIDER: skynet",
+		},
+		{
+			"invalid temperature",
+			map[string]string{"AI_PROVIDER_API_KEY": "key", "AI_TEMPERATURE": "warm"},
+			`getAIConfig: AI_TEMPERATURE: strconv.ParseFloat: parsing "warm": invalid syntax`,
+		},
+		{
+			"invalid timeout",
+			map[string]string{"AI_PROVIDER_API_KEY": "key", "AI_TIMEOUT": "60"},
+			`getAIConfig: AI_TIMEOUT: time: missing unit in duration "60"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, key := range []string{"AI_PROVIDER", "AI_PROVIDER_BASEURL", "AI_PROVIDER_API_KEY", "OPENAI_API_KEY", "AI_MODEL", "AI_TEMPERATURE", "AI_TIMEOUT", "AI_VISION", "AI_PRICE_PER_MILLION_TOKENS", "AI_MONTHLY_BUDGET", "AI_BUDGET_FALLBACK_MODEL"} {
+				t.Setenv(key, tc.env[key])
+			}
+
+			conf, err := getAIConfig()
+			if err != nil {
+				assert.Equal(t, err.Error(), tc.expect)
+				return
+			}
+
+			temperature := "<nil>"
+			if conf.Temperature != nil {
+				temperature = fmt.Sprint(*conf.Temperature)
+			}
+
+			assert.Equal(t, fmt.Sprintf("%s %s %s temperature=%s timeout=%s vision=%v", conf.BaseURL, conf.Model, conf.APIKey, temperature, conf.Timeout, conf.Vision), tc.expect)
+		})
+	}
+}
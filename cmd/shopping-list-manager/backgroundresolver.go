@@ -0,0 +1,96 @@
+package main
+
+// Resolves product details of unknown barcodes in the background, so that a scan can be acknowledged
+// immediately. (Web search + AI can take several seconds.)
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/function61/gokit/sync/syncutil"
+)
+
+const (
+	backgroundResolverWorkers   = 2
+	backgroundResolverQueueSize = 32
+)
+
+type backgroundResolver struct {
+	queue    chan string
+	list     ShoppingList
+	announce func(ctx context.Context, message string)
+	logger   *slog.Logger
+}
+
+func newBackgroundResolver(list ShoppingList, announce func(ctx context.Context, message string), logger *slog.Logger) *backgroundResolver {
+	return &backgroundResolver{
+		queue:    make(chan string, backgroundResolverQueueSize),
+		list:     list,
+		announce: announce,
+		logger:   logger,
+	}
+}
+
+// returns false if the queue is full (the barcode will stay as a placeholder on the shopping list)
+func (b *backgroundResolver) Enqueue(barcode string) bool {
+	select {
+	case b.queue <- barcode:
+		return true
+	default:
+		b.logger.Warn("backgroundResolver: queue full; dropping", "barcode", barcode)
+		return false
+	}
+}
+
+func (b *backgroundResolver) Run(ctx context.Context, workers int) error {
+	return syncutil.Concurrently2(ctx, workers, func(ctx context.Context, barcode string) error {
+		b.announce(ctx, b.resolve(ctx, barcode))
+		return nil // failing to resolve one barcode is no reason to stop the workers
+	}, func(ctx context.Context, work chan string) error {
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case barcode := <-b.queue:
+				select {
+				case work <- barcode:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		}
+	})
+}
+
+// returns message to announce to the user
+func (b *backgroundResolver) resolve(ctx context.Context, barcode string) string {
+	loc := getLocale()
+
+	db, err := loadDB()
+	if err != nil {
+		b.logger.Error("backgroundResolver", "err", err)
+		return loc.Message("audio.error_looking_up")
+	}
+
+	// also renames the placeholder task on the shopping list
+	details, err := resolveProductDetailsByBarcode(ctx, barcode, db, b.list, b.logger)
+	if err != nil {
+		b.logger.Error("backgroundResolver: unable to resolve", "barcode", barcode, "err", err)
+		if message, isServiceError := audioFeedbackForServiceError(err); isServiceError {
+			return message
+		}
+		return loc.Message("audio.looked_up_unrecognized")
+	}
+
+	if _, err := recordLastScanned(barcode, *details); err != nil {
+		b.logger.Error("backgroundResolver: recordLastScanned", "err", err)
+	}
+
+	b.logger.Info("resolved", "barcode", barcode, "ProductName", details.Name)
+
+	if type_ := details.ProductType; type_ != "" {
+		return loc.Message("audio.looked_up", type_)
+	} else {
+		return loc.Message("audio.looked_up", details.Name)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+// Backfills missing product type / category (and display name, size) of local DB entries using the AI
+// assistant. Older entries (e.g. from `misses-record` or from before we had categories) lack these, so
+// they get no emoji or ordering in the shopping list.
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/function61/gokit/encoding/jsonfile"
+	"github.com/joonas-fi/shopping-list-manager/pkg/websearch"
+	"github.com/samber/lo"
+)
+
+const (
+	// barcodes already processed, so an interrupted run can continue where it left off
+	enrichProgressName = "enrich-progress.json"
+)
+
+type enrichOptions struct {
+	ApproveAll bool          // batch mode: apply all proposals without asking
+	DryRun     bool          // only show the proposals
+	Interval   time.Duration // minimum time between AI requests
+	Limit      int           // stop after this many entries (0 = no limit)
+	Restart    bool          // ignore progress from a previous run
+}
+
+type enrichProgress struct {
+	Processed map[string]time.Time `json:"processed"` // barcode => when
+}
+
+// entries that would benefit from enrichment: missing fields or category we couldn't decide on
+func enrichCandidates(db LocalDB) []string {
+	candidates := []string{}
+	for barcode, product := range db {
+		if product.IsUnrecognizedBarcode() { // nothing to go on
+			continue
+		}
+
+		if product.ProductType == "" || product.ProductCategory == "" || product.ProductCategory == productCategories[0].Label {
+			candidates = append(candidates, barcode)
+		}
+	}
+
+	slices.Sort(candidates) // deterministic order makes the progress easier to follow
+
+	return candidates
+}
+
+// asks the AI using what we already know about the product (name, link and notes). apply the guess
+// with `applyEnrichment()`.
+func guessEnrichment(ctx context.Context, ai chatCompleter, conf aiConfig, product productDetails, logger *slog.Logger) (*productDetails, error) {
+	knownDetails := websearch.Result{
+		Title:   product.Name,
+		Snippet: product.Notes,
+		Link:    product.Link,
+	}
+
+	return guessProductDetailsWithAI(ctx, ai, conf, []websearch.Result{knownDetails}, "", product.Link, logger)
+}
+
+// only fills in what is missing. the stored name is kept because it may have been given by a human.
+func applyEnrichment(product productDetails, guess productDetails) productDetails {
+	enriched := product
+
+	if product.ProductType == "" {
+		enriched.ProductType = guess.ProductType
+	}
+	if product.ProductCategory == "" || product.ProductCategory == productCategories[0].Label {
+		enriched.ProductCategory = guess.ProductCategory
+	}
+	if product.DisplayName == "" {
+		enriched.DisplayName = guess.DisplayName
+	}
+	if product.Size == "" {
+		enriched.Size = guess.Size
+	}
+
+	// don't overwrite human notes, but AI's notes explain e.g. why it's unsure
+	if guess.Notes != "" && !strings.Contains(product.Notes, guess.Notes) {
+		enriched.Notes = strings.TrimSpace(product.Notes + "\n" + guess.Notes)
+	}
+
+	enriched.Confidence = guess.Confidence
+	enriched.NeedsReview = product.NeedsReview || guess.NeedsReview
+
+	return enriched
+}
+
+// human-readable list of changed fields
+func enrichmentChanges(before productDetails, after productDetails) []string {
+	changes := []string{}
+	change := func(field string, from string, to string) {
+		if from != to {
+			changes = append(changes, fmt.Sprintf("%s: %q => %q", field, from, to))
+		}
+	}
+
+	change("product type", before.ProductType, after.ProductType)
+	change("category", before.ProductCategory, after.ProductCategory)
+	change("display name", before.DisplayName, after.DisplayName)
+	change("size", before.Size, after.Size)
+	change("notes", before.Notes, after.Notes)
+
+	return changes
+}
+
+// applied changes are stored like any other product details update, so items on the shopping list get
+// renamed / re-categorized as well
+func dbEnrich(ctx context.Context, opts enrichOptions, list ShoppingList, in io.Reader, out io.Writer, logger *slog.Logger) error {
+	withErr := func(err error) error { return fmt.Errorf("dbEnrich: %w", err) }
+
+	conf, err := getAIConfig()
+	if err != nil {
+		return withErr(err)
+	}
+	ai := conf.Client()
+
+	db, err := loadDB()
+	if err != nil {
+		return withErr(err)
+	}
+
+	progress, err := loadEnrichProgress()
+	if err != nil {
+		return withErr(err)
+	}
+	if opts.Restart {
+		progress.Processed = map[string]time.Time{}
+	}
+
+	candidates := lo.Filter(enrichCandidates(*db), func(barcode string, _ int) bool {
+		_, alreadyProcessed := progress.Processed[barcode]
+		return !alreadyProcessed
+	})
+	if opts.Limit > 0 && len(candidates) > opts.Limit {
+		candidates = candidates[:opts.Limit]
+	}
+
+	_, _ = fmt.Fprintf(out, "%d entries to enrich (%d processed earlier)\n", len(candidates), len(progress.Processed))
+
+	answers := bufio.NewScanner(in)
+	applied := 0
+
+	for idx, barcode := range candidates {
+		if idx > 0 { // rate limiting. AI providers' free tiers have quite strict limits
+			select {
+			case <-ctx.Done():
+				return withErr(ctx.Err())
+			case <-time.After(opts.Interval):
+			}
+		}
+
+		product := (*db)[barcode]
+
+		guess, err := guessEnrichment(ctx, ai, *conf, product, logger)
+		if err != nil { // don't give up on the whole run because of one entry. it'll be retried next run.
+			logger.Error("guessEnrichment", "barcode", barcode, "err", err)
+			continue
+		}
+
+		proposal := applyEnrichment(product, *guess)
+
+		changes := enrichmentChanges(product, proposal)
+
+		_, _ = fmt.Fprintf(out, "\n[%d/%d] %s %s (confidence %s)\n", idx+1, len(candidates), barcode, product.Name, proposal.ConfidenceHumanized())
+		for _, change := range changes {
+			_, _ = fmt.Fprintf(out, "  %s\n", change)
+		}
+
+		apply := func() (bool, error) {
+			switch {
+			case opts.DryRun || len(changes) == 0:
+				return false, nil
+			case opts.ApproveAll:
+				return true, nil
+			default:
+				_, _ = fmt.Fprint(out, "apply? [y/N/q] ")
+				if !answers.Scan() { // end of input
+					return false, io.EOF
+				}
+
+				switch strings.ToLower(strings.TrimSpace(answers.Text())) {
+				case "y", "yes":
+					return true, nil
+				case "q", "quit":
+					return false, io.EOF
+				default:
+					return false, nil
+				}
+			}
+		}
+
+		shouldApply, err := apply()
+		if err != nil {
+			if errors.Is(err, io.EOF) { // user wants to stop. progress so far is saved.
+				break
+			}
+			return withErr(err)
+		}
+
+		if shouldApply {
+			// applied to the current details, since `product` might be stale by now (scanned meanwhile etc.)
+			if err := updateProductDetails(ctx, barcode, func(current productDetails, _ bool) (productDetails, error) {
+				return applyEnrichment(current, *guess), nil
+			}, list); err != nil {
+				return withErr(err)
+			}
+
+			applied++
+		}
+
+		if !opts.DryRun {
+			progress.Processed[barcode] = time.Now().UTC()
+
+			if err := jsonfile.Write(enrichProgressName, progress); err != nil {
+				return withErr(err)
+			}
+		}
+	}
+
+	_, _ = fmt.Fprintf(out, "\napplied %d change(s)\n", applied)
+
+	return nil
+}
+
+func loadEnrichProgress() (*enrichProgress, error) {
+	progress := &enrichProgress{Processed: map[string]time.Time{}}
+	if err := jsonfile.ReadDisallowUnknownFields(enrichProgressName, progress); err != nil {
+		if errors.Is(err, fs.ErrNotExist) { // allowed to not exist - fresh run then
+			return progress, nil
+		} else { // some other error
+			return nil, err
+		}
+	}
+	return progress, nil
+}
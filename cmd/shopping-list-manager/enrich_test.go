@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/function61/gokit/testing/assert"
+)
+
+func TestEnrichCandidates(t *testing.T) {
+	db := LocalDB{
+		"3": productDetails{Name: "Maito", ProductType: "Maito", ProductCategory: "Other"},
+		"1": productDetails{Name: "Kaurasämpylä"},
+		"2": productDetails{Name: "Ruisleipä", ProductType: "Ruisleipä", ProductCategory: "Bakery / Bread"},
+		"4": productDetails{Name: taskNameForUnnamedBarcode("4")},
+	}
+
+	assert.Equal(t, len(enrichCandidates(db)), 2)
+	assert.Equal(t, enrichCandidates(db)[0], "1")
+	assert.Equal(t, enrichCandidates(db)[1], "3")
+}
+
+func TestApplyEnrichment(t *testing.T) {
+	product := productDetails{Name: "Vaasan Voimallus Kaurasämpylä 480 g", ProductType: "Sämpylä", Notes: "from the corner store"}
+
+	enriched := applyEnrichment(product, productDetails{
+		Name:            "Voimallus",
+		DisplayName:     "Kaurasämpylä",
+		Size:            "480 g",
+		ProductType:     "Leipä",
+		ProductCategory: "Bakery / Bread",
+		Notes:           "brand is Vaasan",
+		Confidence:      0.9,
+	})
+
+	assert.Equal(t, enriched.Name, "Vaasan Voimallus Kaurasämpylä 480 g")
+	assert.Equal(t, enriched.ProductType, "Sämpylä")
+	assert.Equal(t, enriched.ProductCategory, "Bakery / Bread")
+	assert.Equal(t, enriched.DisplayName, "Kaurasämpylä")
+	assert.Equal(t, enriched.Notes, "from the corner store\nbrand is Vaasan")
+	assert.Equal(t, enriched.NeedsReview, false)
+
+	assert.Equal(t, enrichmentChanges(product, enriched)[0], `category: "" => "Bakery / Bread"`)
+}
@@ -0,0 +1,158 @@
+package main
+
+// Offline evaluation of barcode naming quality. Runs the resolution pipeline against a dataset of
+// recorded web search results with expected outcomes, so changes to the prompt or model can be
+// judged objectively instead of blindly.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/function61/gokit/encoding/jsonfile"
+	"github.com/joonas-fi/shopping-list-manager/pkg/openai"
+	"github.com/joonas-fi/shopping-list-manager/pkg/websearch"
+)
+
+type evalItem struct {
+	Barcode       string             `json:"barcode"`
+	SearchResults []websearch.Result `json:"search_results"`
+	// recorded AI answers for replaying. more than one if the first answer failed validation.
+	AIAnswers []string     `json:"ai_answers,omitempty"`
+	Expected  evalExpected `json:"expected"`
+}
+
+type evalExpected struct {
+	Name            string `json:"name"`
+	ProductType     string `json:"product_type"`
+	ProductCategory string `json:"product_category"`
+}
+
+type evalItemResult struct {
+	evalItem
+	Got             productDetails
+	NameMatches     bool
+	TypeMatches     bool
+	CategoryMatches bool
+}
+
+func loadEvalDataset(path string) ([]evalItem, error) {
+	dataset := []evalItem{}
+	return dataset, jsonfile.ReadDisallowUnknownFields(path, &dataset)
+}
+
+// if replayAI, the AI answers recorded in the dataset are used instead of asking the configured AI provider
+func evaluate(ctx context.Context, dataset []evalItem, replayAI bool, logger *slog.Logger) ([]evalItemResult, error) {
+	conf, err := func() (*aiConfig, error) {
+		if replayAI {
+			return &aiConfig{Model: "replay", Timeout: time.Minute}, nil
+		} else {
+			return getAIConfig()
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	results := []evalItemResult{}
+
+	for _, item := range dataset {
+		if len(item.SearchResults) == 0 {
+			return nil, fmt.Errorf("%s: no search results", item.Barcode)
+		}
+
+		ai := func() chatCompleter {
+			if replayAI {
+				return &replayedChatCompleter{answers: item.AIAnswers}
+			} else {
+				return conf.Client()
+			}
+		}()
+
+		got := productDetailsFromSearchResults(ctx, item.SearchResults, func(ctx context.Context, searchResults []websearch.Result, link string, logger *slog.Logger) (*productDetails, error) {
+			return guessProductDetailsWithAI(ctx, ai, *conf, searchResults, "", link, logger)
+		}, logger)
+
+		same := func(a, b string) bool { return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b)) }
+
+		results = append(results, evalItemResult{
+			evalItem:        item,
+			Got:             got,
+			NameMatches:     same(got.Name, item.Expected.Name),
+			TypeMatches:     same(got.ProductType, item.Expected.ProductType),
+			CategoryMatches: got.ProductCategory == item.Expected.ProductCategory,
+		})
+	}
+
+	return results, nil
+}
+
+func printEvalReport(results []evalItemResult, output io.Writer) {
+	diff := func(field string, got string, expected string) {
+		_, _ = fmt.Fprintf(output, "    %s: got %q, expected %q\n", field, got, expected)
+	}
+
+	names, types, categories := 0, 0, 0
+
+	for _, result := range results {
+		if result.NameMatches && result.TypeMatches && result.CategoryMatches {
+			_, _ = fmt.Fprintf(output, "✔ %s %s\n", result.Barcode, result.Got.Name)
+		} else {
+			_, _ = fmt.Fprintf(output, "✘ %s\n", result.Barcode)
+		}
+
+		if result.NameMatches {
+			names++
+		} else {
+			diff("name", result.Got.Name, result.Expected.Name)
+		}
+
+		if result.TypeMatches {
+			types++
+		} else {
+			diff("type", result.Got.ProductType, result.Expected.ProductType)
+		}
+
+		if result.CategoryMatches {
+			categories++
+		} else {
+			diff("category", result.Got.ProductCategory, result.Expected.ProductCategory)
+		}
+	}
+
+	percentage := func(count int) string {
+		if len(results) == 0 {
+			return fmt.Sprintf("%d/0", count)
+		}
+		return fmt.Sprintf("%d/%d (%.0f %%)", count, len(results), float64(count)/float64(len(results))*100)
+	}
+
+	_, _ = fmt.Fprintf(output, "\nName exact match:  %s\nType match:        %s\nCategory accuracy: %s\n",
+		percentage(names),
+		percentage(types),
+		percentage(categories))
+}
+
+// answers with recorded answers, in order
+type replayedChatCompleter struct {
+	answers []string
+}
+
+func (r *replayedChatCompleter) ChatCompletion(_ context.Context, _ openai.ChatCompletionReq) (*openai.ChatCompletionRes, error) {
+	if len(r.answers) == 0 {
+		return nil, errors.New("replayedChatCompleter: no more recorded answers")
+	}
+
+	answer := r.answers[0]
+	r.answers = r.answers[1:]
+
+	return &openai.ChatCompletionRes{
+		Choices: []openai.ChatCompletionChoice{
+			{Message: openai.ChatMessage{Role: "assistant", Content: answer}},
+		},
+	}, nil
+}
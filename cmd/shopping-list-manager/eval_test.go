@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/function61/gokit/testing/assert"
+)
+
+func TestEvaluateReplay(t *testing.T) {
+	dataset, err := loadEvalDataset("testdata/eval-dataset.json")
+	assert.Ok(t, err)
+
+	results, err := evaluate(context.Background(), dataset, true, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	assert.Ok(t, err)
+
+	output := &bytes.Buffer{}
+	printEvalReport(results, output)
+
+	assert.Equal(t, output.String(), `✔ 6408180733659 Vaasan Voimallus Kaurasämpylä kaurainen sämpylä 480 g 8 kpl
+✔ 7311070347234 Santa Maria Tacokastike Medium 230 g
+✘ 6411401015090
+    name: got "Fazer Sininen maitosuklaalevy 200g", expected "Fazer Sininen maitosuklaalevy 200 g"
+    category: got "Baking Supplies", expected "Snacks"
+✘ 6410405082657
+    type: got "", expected "Milk"
+    category: got "", expected "Dairy & Eggs"
+
+Name exact match:  3/4 (75 %)
+Type match:        3/4 (75 %)
+Category accuracy: 2/4 (50 %)
+`)
+}
@@ -0,0 +1,190 @@
+package main
+
+// Grocy integration: Grocy's product database is asked before web search, AI-resolved products are pushed
+// to Grocy, and scans can consume stock instead of adding to the shopping list.
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/function61/gokit/os/osutil"
+	"github.com/joonas-fi/shopping-list-manager/pkg/grocy"
+	"github.com/samber/lo"
+)
+
+const (
+	scanModeShoppingList = "shopping-list" // scanned items are added to the shopping list
+	scanModeConsume      = "consume"       // scanned items are consumed from Grocy's stock
+)
+
+var errGrocyNotConfigured = errors.New("Grocy not configured (GROCY_URL)")
+
+// nil if Grocy is not in use
+func getGrocy() (*grocy.Client, error) {
+	baseURL := os.Getenv("GROCY_URL")
+	if baseURL == "" {
+		return nil, nil
+	}
+
+	apiKey, err := osutil.GetenvRequired("GROCY_API_KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	return grocy.NewClient(baseURL, apiKey), nil
+}
+
+// "SCAN_MODE=consume"
+func getScanMode() (string, error) {
+	switch mode := cmp.Or(os.Getenv("SCAN_MODE"), scanModeShoppingList); mode {
+	case scanModeShoppingList, scanModeConsume:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported SCAN_MODE: %s", mode)
+	}
+}
+
+// returns nil if Grocy is not in use or it doesn't know the barcode
+func grocyResolveProductByBarcode(ctx context.Context, barcode string, client *grocy.Client) (*productDetails, error) {
+	withErr := func(err error) (*productDetails, error) {
+		return nil, fmt.Errorf("grocyResolveProductByBarcode: %w", err)
+	}
+
+	if client == nil {
+		return nil, nil
+	}
+
+	product, err := client.ProductByBarcode(ctx, barcode)
+	if err != nil || product == nil {
+		return nil, err
+	}
+
+	details := newProductDetails(product.Name, "")
+	details.Notes = product.Description
+	details.Source = productSourceGrocy
+
+	if product.ProductGroupID != 0 {
+		groups, err := client.ProductGroups(ctx)
+		if err != nil {
+			return withErr(err)
+		}
+
+		if group, found := lo.Find(groups, func(group grocy.ProductGroup) bool { return group.ID == product.ProductGroupID }); found {
+			if category := categoryOfProductGroup(group); category != nil {
+				details.ProductCategory = category.Label
+			}
+		}
+	}
+
+	return &details, nil
+}
+
+// product groups named like our categories work as categories. nil if the group is not one of them.
+func categoryOfProductGroup(group grocy.ProductGroup) *productCategoryItem {
+	category, _ := resolveProductCategory(group.Name)
+	return category
+}
+
+// makes Grocy know the barcode. if Grocy already has a product with the same name, the barcode is
+// added to it. otherwise the product is created (if we know where to create it).
+func pushProductToGrocy(ctx context.Context, barcode string, product productDetails, client *grocy.Client) error {
+	withErr := func(err error) error { return fmt.Errorf("pushProductToGrocy: %w", err) }
+
+	existing, err := client.ProductByName(ctx, product.Name)
+	if err != nil {
+		return withErr(err)
+	}
+
+	productID := grocy.ID(0)
+	if existing != nil {
+		productID = existing.ID
+	} else {
+		newProduct, err := grocyNewProduct(product)
+		if err != nil {
+			return withErr(err)
+		}
+
+		groups, err := client.ProductGroups(ctx)
+		if err != nil {
+			return withErr(err)
+		}
+		if group, found := lo.Find(groups, func(group grocy.ProductGroup) bool {
+			category := categoryOfProductGroup(group)
+			return category != nil && category.Label == product.ProductCategory
+		}); found {
+			newProduct.ProductGroupID = int(group.ID)
+		}
+
+		productID, err = client.CreateProduct(ctx, *newProduct)
+		if err != nil {
+			return withErr(err)
+		}
+	}
+
+	if err := client.AddBarcode(ctx, productID, barcode); err != nil {
+		return withErr(err)
+	}
+
+	return nil
+}
+
+// Grocy requires a location and quantity units for products. we use the same quantity unit for all
+// purposes ("piece" is usually what you want).
+func grocyNewProduct(product productDetails) (*grocy.NewProduct, error) {
+	locationID, err := strconv.Atoi(os.Getenv("GROCY_LOCATION_ID"))
+	if err != nil {
+		return nil, fmt.Errorf("GROCY_LOCATION_ID: %w", err)
+	}
+
+	quantityUnitID, err := strconv.Atoi(os.Getenv("GROCY_QUANTITY_UNIT_ID"))
+	if err != nil {
+		return nil, fmt.Errorf("GROCY_QUANTITY_UNIT_ID: %w", err)
+	}
+
+	return &grocy.NewProduct{
+		Name:         product.Name,
+		Description:  product.Notes,
+		LocationID:   locationID,
+		QuIDPurchase: quantityUnitID,
+		QuIDStock:    quantityUnitID,
+		QuIDConsume:  quantityUnitID,
+		QuIDPrice:    quantityUnitID,
+	}, nil
+}
+
+// only confident AI guesses are pushed. guesses that need review would pollute Grocy's product database.
+func pushToGrocyIfAIResolved(ctx context.Context, barcode string, product productDetails, logger *slog.Logger) {
+	if product.Source != productSourceAI || product.NeedsReview {
+		return
+	}
+
+	if os.Getenv("GROCY_LOCATION_ID") == "" { // pushing not enabled
+		return
+	}
+
+	client, err := getGrocy()
+	if err != nil || client == nil {
+		return
+	}
+
+	if err := pushProductToGrocy(ctx, barcode, product, client); err != nil {
+		logger.Error("pushToGrocyIfAIResolved", "err", err) // not critical
+	}
+}
+
+func consumeFromGrocy(ctx context.Context, barcode string) error {
+	client, err := getGrocy()
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		return errGrocyNotConfigured
+	}
+
+	return client.ConsumeByBarcode(ctx, barcode)
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/function61/gokit/testing/assert"
+	"github.com/samber/lo"
+)
+
+// fake Grocy API with just enough of the generic objects API and stock API for us
+type grocyFake struct {
+	objects  map[string][]map[string]any // entity => objects
+	consumed []string                    // barcodes
+}
+
+func newGrocyFake(t *testing.T) (*httptest.Server, *grocyFake) {
+	fake := &grocyFake{objects: map[string][]map[string]any{
+		"products": {
+			{"id": 1, "name": "Maito", "description": "Kevytmaito, 1 l", "product_group_id": "2"}, // older Grocy versions use strings
+		},
+		"product_barcodes": {
+			{"id": 1, "product_id": 1, "barcode": "6408430000258"},
+		},
+		"product_groups": {
+			{"id": 1, "name": "Produce (Fruits & Vegetables)"},
+			{"id": 2, "name": "Dairy & Eggs"},
+		},
+		"shopping_list": {},
+	}}
+
+	productByBarcode := func(barcode string) map[string]any {
+		for _, productBarcode := range fake.objects["product_barcodes"] {
+			if productBarcode["barcode"] == barcode {
+				return fake.find("products", fmt.Sprint(productBarcode["product_id"]))
+			}
+		}
+		return nil
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("GROCY-API-KEY") != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		respond := func(body any) {
+			w.Header().Set("Content-Type", "application/json")
+			assert.Ok(t, json.NewEncoder(w).Encode(body))
+		}
+
+		path := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/"), "/")
+
+		switch {
+		case path[0] == "stock" && len(path) == 5 && r.Method == http.MethodPost: // .../<barcode>/consume
+			if productByBarcode(path[3]) == nil {
+				http.Error(w, `{"error_message":"No product with barcode found"}`, http.StatusBadRequest)
+				return
+			}
+			fake.consumed = append(fake.consumed, path[3])
+			respond([]any{})
+		case path[0] == "objects" && len(path) == 2 && r.Method == http.MethodGet:
+			respond(lo.Filter(fake.objects[path[1]], func(object map[string]any, _ int) bool {
+				for _, condition := range r.URL.Query()["query[]"] {
+					key, value, _ := strings.Cut(condition, "=")
+					if fmt.Sprint(object[key]) != value {
+						return false
+					}
+				}
+				return true
+			}))
+		case path[0] == "objects" && len(path) == 2 && r.Method == http.MethodPost:
+			object := map[string]any{}
+			assert.Ok(t, json.NewDecoder(r.Body).Decode(&object))
+			object["id"] = len(fake.objects[path[1]]) + 1
+			if path[1] == "shopping_list" {
+				object["done"] = 0
+			}
+			fake.objects[path[1]] = append(fake.objects[path[1]], object)
+			respond(map[string]any{"created_object_id": object["id"]})
+		case path[0] == "objects" && len(path) == 3 && r.Method == http.MethodPut:
+			object := fake.find(path[1], path[2])
+			assert.Ok(t, json.NewDecoder(r.Body).Decode(&object))
+			w.WriteHeader(http.StatusNoContent)
+		case path[0] == "objects" && len(path) == 3 && r.Method == http.MethodDelete:
+			fake.objects[path[1]] = lo.Filter(fake.objects[path[1]], func(object map[string]any, _ int) bool { return fmt.Sprint(object["id"]) != path[2] })
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "not implemented in fake", http.StatusNotImplemented)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Setenv("GROCY_URL", srv.URL)
+	t.Setenv("GROCY_API_KEY", "secret")
+
+	return srv, fake
+}
+
+func (g *grocyFake) find(entity string, id string) map[string]any {
+	object, _ := lo.Find(g.objects[entity], func(object map[string]any) bool { return fmt.Sprint(object["id"]) == id })
+	return object
+}
+
+func TestGrocyResolveProductByBarcode(t *testing.T) {
+	ctx := context.Background()
+	newGrocyFake(t)
+
+	client, err := getGrocy()
+	assert.Ok(t, err)
+
+	product, err := grocyResolveProductByBarcode(ctx, "6408430000258", client)
+	assert.Ok(t, err)
+	assert.Equal(t, product.Name, "Maito")
+	assert.Equal(t, product.Notes, "Kevytmaito, 1 l")
+	assert.Equal(t, product.ProductCategory, "Dairy & Eggs")
+	assert.Equal(t, product.Source, productSourceGrocy)
+
+	product, err = grocyResolveProductByBarcode(ctx, "123456789012", client)
+	assert.Ok(t, err)
+	assert.Equal(t, product == nil, true)
+
+	product, err = grocyResolveProductByBarcode(ctx, "6408430000258", nil) // Grocy not in use
+	assert.Ok(t, err)
+	assert.Equal(t, product == nil, true)
+}
+
+func TestPushProductToGrocy(t *testing.T) {
+	ctx := context.Background()
+	_, fake := newGrocyFake(t)
+	t.Setenv("GROCY_LOCATION_ID", "3")
+	t.Setenv("GROCY_QUANTITY_UNIT_ID", "4")
+
+	client, err := getGrocy()
+	assert.Ok(t, err)
+
+	// known product => only the barcode is added
+	assert.Ok(t, pushProductToGrocy(ctx, "6408430000259", productDetails{Name: "Maito"}, client))
+
+	// unknown product => created
+	assert.Ok(t, pushProductToGrocy(ctx, "6410405082657", productDetails{
+		Name:            "Banaani",
+		Notes:           "Reilun kaupan",
+		ProductCategory: "Produce (Fruits & Vegetables)",
+	}, client))
+
+	assert.Equal(t, len(fake.objects["products"]), 2)
+	banana := fake.objects["products"][1]
+	assert.Equal(t, fmt.Sprintf("%v|%v|%v|%v|%v", banana["name"], banana["description"], banana["product_group_id"], banana["location_id"], banana["qu_id_stock"]), "Banaani|Reilun kaupan|1|3|4")
+
+	barcodes := lo.Map(fake.objects["product_barcodes"], func(object map[string]any, _ int) string {
+		return fmt.Sprintf("%v=%v", object["barcode"], object["product_id"])
+	})
+	assert.Equal(t, strings.Join(barcodes, ","), "6408430000258=1,6408430000259=1,6410405082657=2")
+
+	product, err := grocyResolveProductByBarcode(ctx, "6410405082657", client)
+	assert.Ok(t, err)
+	assert.Equal(t, product.ProductCategory, "Produce (Fruits & Vegetables)")
+
+	// guesses that need review are not pushed
+	pushToGrocyIfAIResolved(ctx, "6416453012345", productDetails{Name: "Jotain", Source: productSourceAI, NeedsReview: true}, slog.Default())
+	assert.Equal(t, len(fake.objects["product_barcodes"]), 3)
+}
+
+func TestGrocyShoppingList(t *testing.T) {
+	ctx := context.Background()
+	_, fake := newGrocyFake(t)
+	t.Setenv("GROCY_SHOPPING_LIST_ID", "2")
+
+	list, err := newGrocyShoppingList()
+	assert.Ok(t, err)
+
+	_, err = list.Add(ctx, ShoppingListItem{Name: "Maito", Barcode: "6408430000258"}) // known to Grocy => linked to the product
+	assert.Ok(t, err)
+	_, err = list.Add(ctx, ShoppingListItem{Name: taskNameForUnnamedBarcode("123"), Barcode: "123"})
+	assert.Ok(t, err)
+
+	added := lo.Map(fake.objects["shopping_list"], func(object map[string]any, _ int) string {
+		return fmt.Sprintf("%v|%v|%v", object["shopping_list_id"], object["product_id"], object["note"])
+	})
+	assert.Equal(t, strings.Join(added, "\n"), `2|1|Maito
+2|<nil>|`+taskNameForUnnamedBarcode("123"))
+
+	assert.Ok(t, renameTasksInternal(ctx, []string{taskNameForUnnamedBarcode("123")}, taskSpec{Name: "Banaani"}, nil, list))
+	assert.Equal(t, fake.find("shopping_list", "2")["note"], "Banaani")
+
+	assert.Ok(t, list.Complete(ctx, "1"))
+
+	fake.objects["shopping_list"] = append(fake.objects["shopping_list"], map[string]any{"id": 3, "shopping_list_id": 2, "product_id": 1, "note": "", "done": 0}) // added in Grocy's UI
+
+	items, err := list.Items(ctx, false)
+	assert.Ok(t, err)
+	assert.Equal(t, strings.Join(lo.Map(items, func(item ShoppingListItem, _ int) string { return item.ID + "=" + item.Name }), ","), "2=Banaani,3=Maito")
+
+	assert.Ok(t, list.Remove(ctx, "3"))
+
+	assert.Ok(t, list.Remove(ctx, "2"))
+	assert.Equal(t, len(fake.objects["shopping_list"]), 1) // the completed one
+}
+
+func TestScanInConsumeMode(t *testing.T) {
+	ctx := context.Background()
+	_, fake := newGrocyFake(t)
+	t.Setenv("SCAN_MODE", scanModeConsume)
+	t.Setenv("LOCALE", "en")
+
+	// shopping list is not touched when consuming
+	assert.Equal(t, handleScan(ctx, "6408430000258", slog.Default(), nil, nil), "Consumed from stock")
+	assert.Equal(t, handleScan(ctx, "https://id.gs1.org/01/06408430000258", slog.Default(), nil, nil), "Consumed from stock")
+	assert.Equal(t, handleScan(ctx, "123456789012", slog.Default(), nil, nil), "Error consuming from stock")
+
+	assert.Equal(t, strings.Join(fake.consumed, ","), "6408430000258,6408430000258")
+}
+
+func TestGrocyProductByNameWithQuerySyntax(t *testing.T) {
+	ctx := context.Background()
+	_, fake := newGrocyFake(t)
+	fake.objects["products"] = append(fake.objects["products"], map[string]any{"id": 2, "name": "Kerma <15%"})
+
+	client, err := getGrocy()
+	assert.Ok(t, err)
+
+	product, err := client.ProductByName(ctx, "Kerma <15%")
+	assert.Ok(t, err)
+	assert.Equal(t, product.ID.String(), "2")
+
+	product, err = client.ProductByName(ctx, "Mai~") // would be a "contains" filter for Grocy
+	assert.Ok(t, err)
+	assert.Equal(t, product == nil, true)
+}
@@ -0,0 +1,172 @@
+package main
+
+// Todoist labels derived from product details, so the list can be filtered by e.g. "needs-review",
+// "ai-guessed" or product type. Which labels are used is configured with TODOIST_LABELS.
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/joonas-fi/shopping-list-manager/pkg/todoist"
+	"github.com/samber/lo"
+)
+
+// where product details came from
+const (
+	productSourceAI        = "ai"
+	productSourceWebSearch = "web-search" // first search result (AI failed or is not in use)
+	productSourceManual    = "manual"
+	productSourceGrocy     = "grocy"
+)
+
+type labelRules struct {
+	needsReview     string            // label name, "" = not in use
+	source          bool              // "ai-guessed" | "web-search" | "manual"
+	productType     bool              // product type as label
+	category        bool              // category as label
+	categoryRenames map[string]string // category label => label name
+}
+
+// "needs_review;source;product_type;category:Personal Care / Health=pharmacy"
+// (semicolon-separated since category labels contain commas)
+func parseLabelRules(spec string) (*labelRules, error) {
+	rules := &labelRules{categoryRenames: map[string]string{}}
+
+	for _, rule := range strings.Split(spec, ";") {
+		rule = strings.TrimSpace(rule)
+
+		field, arg, hasArg := strings.Cut(rule, "=")
+		field, categoryArg, hasCategoryArg := strings.Cut(field, ":")
+
+		switch {
+		case rule == "":
+			continue
+		case field == "needs_review" && !hasCategoryArg:
+			rules.needsReview = lo.Ternary(hasArg, arg, "needs-review")
+		case rule == "source":
+			rules.source = true
+		case rule == "product_type":
+			rules.productType = true
+		case rule == "category":
+			rules.category = true
+		case field == "category" && hasCategoryArg && hasArg:
+			if category, _ := resolveProductCategory(categoryArg); category == nil {
+				return nil, fmt.Errorf("TODOIST_LABELS: unknown category: %s", categoryArg)
+			}
+			rules.category = true
+			rules.categoryRenames[categoryArg] = arg
+		default:
+			return nil, fmt.Errorf("TODOIST_LABELS: unsupported rule: %s", rule)
+		}
+	}
+
+	return rules, nil
+}
+
+func getLabelRules() (*labelRules, error) {
+	return parseLabelRules(os.Getenv("TODOIST_LABELS"))
+}
+
+func (r labelRules) LabelsForProduct(product productDetails) []string {
+	labels := []string{}
+
+	if r.needsReview != "" && product.NeedsReview {
+		labels = append(labels, r.needsReview)
+	}
+
+	if r.source {
+		switch product.Source {
+		case productSourceAI:
+			labels = append(labels, "ai-guessed")
+		case productSourceWebSearch, productSourceManual, productSourceGrocy:
+			labels = append(labels, product.Source)
+		}
+	}
+
+	if r.productType && product.ProductType != "" {
+		labels = append(labels, labelName(product.ProductType))
+	}
+
+	if r.category && product.ProductCategory != "" {
+		if renamed, found := r.categoryRenames[product.ProductCategory]; found {
+			labels = append(labels, renamed)
+		} else {
+			labels = append(labels, labelName(product.ProductCategory))
+		}
+	}
+
+	return lo.Uniq(lo.Filter(labels, func(label string, _ int) bool { return label != "" }))
+}
+
+// labels for the product, or none if labels are not configured (or configured wrong, which is logged)
+func labelsForProduct(product productDetails) []string {
+	rules, err := getLabelRules()
+	if err != nil {
+		slog.Error("labelsForProduct", "err", err)
+		return nil
+	}
+
+	return rules.LabelsForProduct(product)
+}
+
+// labels that applied to the previous version of product details but not anymore
+func staleLabels(previous productDetails, current productDetails) []string {
+	currentLabels := labelsForProduct(current)
+	return lo.Filter(labelsForProduct(previous), func(label string, _ int) bool { return !slices.Contains(currentLabels, label) })
+}
+
+// "Produce (Fruits & Vegetables)" => "produce-fruits-vegetables"
+func labelName(value string) string {
+	words := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsNumber(r) })
+	return strings.Join(words, "-")
+}
+
+// removes stale labels and adds new ones. labels added by humans are kept.
+func updateLabels(existing []string, add []string, remove []string) []string {
+	kept := lo.Filter(existing, func(label string, _ int) bool { return !slices.Contains(remove, label) })
+	return lo.Uniq(append(kept, add...))
+}
+
+var (
+	knownLabels   = map[string]bool{}
+	knownLabelsMu sync.Mutex
+)
+
+// Todoist would create the missing labels as shared labels, but personal labels are the ones that
+// are visible in the labels view and usable in filters
+func ensureLabelsExist(ctx context.Context, labels []string, todo *todoist.Client) error {
+	knownLabelsMu.Lock()
+	defer knownLabelsMu.Unlock()
+
+	missing := lo.Filter(labels, func(label string, _ int) bool { return !knownLabels[label] })
+	if len(missing) == 0 {
+		return nil
+	}
+
+	existing, err := todo.Labels(ctx)
+	if err != nil {
+		return fmt.Errorf("ensureLabelsExist: %w", err)
+	}
+	for _, label := range existing {
+		knownLabels[label.Name] = true
+	}
+
+	for _, label := range missing {
+		if knownLabels[label] {
+			continue
+		}
+
+		if _, err := todo.CreateLabel(ctx, todoist.Label{Name: label}); err != nil {
+			return fmt.Errorf("ensureLabelsExist: %w", err)
+		}
+		knownLabels[label] = true
+	}
+
+	return nil
+}
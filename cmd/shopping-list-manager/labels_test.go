@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/function61/gokit/testing/assert"
+)
+
+func TestLabelsForProduct(t *testing.T) {
+	labels := func(spec string, product productDetails) string {
+		rules, err := parseLabelRules(spec)
+		if err != nil {
+			return err.Error()
+		}
+		return strings.Join(rules.LabelsForProduct(product), ",")
+	}
+
+	milk := productDetails{Name: "Maito", ProductType: "Milk", ProductCategory: "Dairy & Eggs", Source: productSourceAI, NeedsReview: true}
+
+	assert.Equal(t, labels("", milk), "")
+	assert.Equal(t, labels("needs_review;source;product_type;category", milk), "needs-review,ai-guessed,milk,dairy-eggs")
+	assert.Equal(t, labels("needs_review=check; category:Dairy & Eggs=dairy", milk), "check,dairy")
+	assert.Equal(t, labels("source", productDetails{Source: productSourceManual}), "manual")
+	assert.Equal(t, labels("source;product_type", productDetails{}), "") // older entries have no source
+	assert.Equal(t, labels("category:Dairy=dairy", milk), "TODOIST_LABELS: unknown category: Dairy")
+	assert.Equal(t, labels("brand", milk), "TODOIST_LABELS: unsupported rule: brand")
+}
+
+func TestUpdateLabels(t *testing.T) {
+	assert.Equal(t, strings.Join(updateLabels([]string{"needs-review", "ai-guessed", "urgent"}, []string{"manual"}, []string{"needs-review", "ai-guessed"}), ","), "urgent,manual")
+	assert.Equal(t, strings.Join(updateLabels(nil, []string{"manual", "manual"}, nil), ","), "manual")
+}
+
+func TestLabelName(t *testing.T) {
+	assert.Equal(t, labelName("Produce (Fruits & Vegetables)"), "produce-fruits-vegetables")
+	assert.Equal(t, labelName("Oat drink"), "oat-drink")
+}
@@ -2,7 +2,11 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"io/fs"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/function61/gokit/encoding/jsonfile"
@@ -15,26 +19,47 @@ const (
 
 type productDetails struct {
 	Name            string     `json:"name"`
-	ProductType     string     `json:"product_type"` // milk | butter | juice | ...
+	DisplayName     string     `json:"display_name,omitempty"` // short name for the shopping list, like "Kaurasämpylä"
+	Size            string     `json:"size,omitempty"`         // package size / quantity, like "480 g" or "8 kpl"
+	ProductType     string     `json:"product_type"`           // milk | butter | juice | ...
 	ProductCategory string     `json:"product_category"`
 	Link            string     `json:"link"`
+	Source          string     `json:"source,omitempty"` // productSourceAI | productSourceWebSearch | productSourceManual | productSourceGrocy
 	Notes           string     `json:"notes,omitempty"`
+	Confidence      float64    `json:"confidence,omitempty"`   // 0.0 - 1.0 for AI-resolved products (0 = not known)
+	NeedsReview     bool       `json:"needs_review,omitempty"` // automatically resolved details that a human should check
 	FirstScanned    *time.Time `json:"first_scanned"`
 	LastScanned     *time.Time `json:"last_scanned"`
 }
 
+// display name if we have one, otherwise the full name
+func (p productDetails) ShortName() string {
+	if p.DisplayName != "" {
+		return p.DisplayName
+	}
+	return p.Name
+}
+
 func (p productDetails) IsUnrecognizedBarcode() bool {
 	return identifyMissRe.MatchString(p.Name)
 }
 
 func (p productDetails) LastScannedHumanized() string {
 	if p.LastScanned == nil {
-		return "never"
+		return getLocale().Message("web.never")
 	}
 
 	return timeutil.HumanizeDuration(time.Since(*p.LastScanned))
 }
 
+func (p productDetails) ConfidenceHumanized() string {
+	if p.Confidence == 0 {
+		return getLocale().Message("web.unknown")
+	}
+
+	return fmt.Sprintf("%.0f %%", p.Confidence*100)
+}
+
 type LocalDB map[string]productDetails
 
 func loadDB() (*LocalDB, error) {
@@ -49,10 +74,41 @@ func loadDB() (*LocalDB, error) {
 	return db, nil
 }
 
+// serializes read-modify-write cycles of the DB (scans, background resolving and the web UI happen concurrently)
+var updateDBMu sync.Mutex
+
+func updateDB(modify func(db LocalDB) error) error {
+	updateDBMu.Lock()
+	defer updateDBMu.Unlock()
+
+	db, err := loadDB()
+	if err != nil {
+		return err
+	}
+
+	if err := modify(*db); err != nil {
+		return err
+	}
+
+	return saveDB(*db)
+}
+
 func saveDB(db LocalDB) error {
 	return jsonfile.Write(localDBName, db)
 }
 
+var productSizeRe = regexp.MustCompile(`(?i)(?:^|\s)((?:\d+\s?x\s?)?\d+(?:[.,]\d+)?)\s?(kg|g|mg|l|dl|cl|ml|kpl|pcs|rll)\b`)
+
+// "Vaasan Voimallus Kaurasämpylä kaurainen sämpylä 480 g 8 kpl" => "480 g 8 kpl".
+// for when AI didn't tell the size.
+func parseProductSize(productName string) string {
+	sizes := []string{}
+	for _, match := range productSizeRe.FindAllStringSubmatch(productName, -1) {
+		sizes = append(sizes, strings.ReplaceAll(match[1], " ", "")+" "+strings.ToLower(match[2]))
+	}
+	return strings.Join(sizes, " ")
+}
+
 func localDBresolveProductByBarcode(barcode string, resolveDB *LocalDB) (productDetails, bool) {
 	details, found := (*resolveDB)[barcode]
 	return details, found
@@ -23,3 +23,15 @@ func TestLocalDBresolveProductByBarcode(t *testing.T) {
 	assert.Equal(t, resolve("123"), "not found")
 	assert.Equal(t, resolve("6408180733659"), "Vaasan Voimallus Kaurasämpylä kaurainen sämpylä 480 g 8 kpl")
 }
+
+func TestParseProductSize(t *testing.T) {
+	assert.Equal(t, parseProductSize("Vaasan Voimallus Kaurasämpylä kaurainen sämpylä 480 g 8 kpl"), "480 g 8 kpl")
+	assert.Equal(t, parseProductSize("Olvi Iisalmi 6 x 0,33 l"), "6x0,33 l")
+	assert.Equal(t, parseProductSize("Valio Täysmaito 1L"), "1 l")
+	assert.Equal(t, parseProductSize("Teddy bear"), "")
+}
+
+func TestTaskNameForProduct(t *testing.T) {
+	assert.Equal(t, taskNameForProduct(productDetails{Name: "Vaasan Voimallus Kaurasämpylä 480 g", DisplayName: "Kaurasämpylä", ProductCategory: "Bakery / Bread"}), "🍞 Kaurasämpylä")
+	assert.Equal(t, taskNameForProduct(productDetails{Name: "Teddy bear"}), "Teddy bear")
+}
@@ -0,0 +1,93 @@
+package main
+
+// Localization of user-facing strings (audio feedback, web UI) and of the preferred language of product names.
+// Translations are in message catalogs, one per language, in `locales/`.
+
+import (
+	"cmp"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	. "github.com/function61/gokit/builtin"
+)
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+const (
+	defaultLocale = "en"
+)
+
+// language tag => message key => message
+var messageCatalogs = Must(loadMessageCatalogs())
+
+type locale struct {
+	tag      string
+	messages map[string]string
+}
+
+// reads the `LOCALE` ENV. "fi", "fi-FI" and "fi_FI.UTF-8" are all understood as Finnish.
+func getLocale() locale {
+	tag := strings.ToLower(cmp.Or(os.Getenv("LOCALE"), defaultLocale))
+
+	// "fi_FI.UTF-8" => "fi"
+	if parts := strings.FieldsFunc(tag, func(r rune) bool { return r == '-' || r == '_' || r == '.' }); len(parts) > 0 {
+		if messages, found := messageCatalogs[parts[0]]; found {
+			return locale{parts[0], messages}
+		}
+	}
+
+	return locale{defaultLocale, messageCatalogs[defaultLocale]}
+}
+
+// looks up message by key, formatting it with args (if any). messages missing from the locale's catalog
+// fall back to the default locale.
+func (l locale) Message(key string, args ...any) string {
+	msg, found := l.messages[key]
+	if !found {
+		msg, found = messageCatalogs[defaultLocale][key]
+		if !found {
+			return key // better than nothing
+		}
+	}
+
+	if len(args) == 0 {
+		return msg
+	}
+
+	return fmt.Sprintf(msg, args...)
+}
+
+// the language product names and types should preferably be in, like "Finnish"
+func (l locale) LanguageName() string {
+	return l.Message("language_name")
+}
+
+func loadMessageCatalogs() (map[string]map[string]string, error) {
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		return nil, err
+	}
+
+	catalogs := map[string]map[string]string{}
+
+	for _, file := range files {
+		content, err := localeFiles.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		messages := map[string]string{}
+		if err := json.Unmarshal(content, &messages); err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name(), err)
+		}
+
+		catalogs[strings.TrimSuffix(file.Name(), ".json")] = messages
+	}
+
+	return catalogs, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/function61/gokit/testing/assert"
+)
+
+func TestGetLocale(t *testing.T) {
+	localeFor := func(env string) string {
+		t.Setenv("LOCALE", env)
+		return getLocale().tag
+	}
+
+	assert.Equal(t, localeFor(""), "en")
+	assert.Equal(t, localeFor("fi"), "fi")
+	assert.Equal(t, localeFor("fi_FI.UTF-8"), "fi")
+	assert.Equal(t, localeFor("FI-fi"), "fi")
+	assert.Equal(t, localeFor("sv"), "en") // no catalog => fallback
+	assert.Equal(t, localeFor("-"), "en")
+
+	t.Setenv("LOCALE", "fi")
+	assert.Equal(t, getLocale().Message("audio.added_product_type", "maito"), "Lisätty maito")
+	assert.Equal(t, getLocale().Message("no.such.key"), "no.such.key")
+}
+
+// catch forgotten translations
+func TestMessageCatalogsHaveSameKeys(t *testing.T) {
+	for tag, messages := range messageCatalogs {
+		for key := range messageCatalogs[defaultLocale] {
+			_, found := messages[key]
+			if !found {
+				t.Errorf("locale %s is missing key %s", tag, key)
+			}
+		}
+	}
+}
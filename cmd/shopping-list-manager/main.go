@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"os"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -18,8 +19,11 @@ import (
 	"github.com/function61/gokit/os/osutil"
 	"github.com/function61/gokit/sync/taskrunner"
 	"github.com/joonas-fi/home-audio/pkg/homeaudioclient"
-	"github.com/joonas-fi/shopping-list-manager/pkg/googlesearch"
+	"github.com/joonas-fi/shopping-list-manager/pkg/productpage"
+	"github.com/joonas-fi/shopping-list-manager/pkg/recipe"
+	"github.com/joonas-fi/shopping-list-manager/pkg/resilienthttp"
 	"github.com/joonas-fi/shopping-list-manager/pkg/todoist"
+	"github.com/joonas-fi/shopping-list-manager/pkg/websearch"
 	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 )
@@ -36,12 +40,14 @@ func main() {
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			ctx := cmd.Context()
 
-			todo, err := getClient()
+			list, err := getShoppingList()
 			if err != nil {
 				return err
 			}
 
-			beep := make(chan string, 2)
+			// handling a beep is quick (unknown barcodes are resolved in the background), but leave
+			// some room for rapid-fire scans
+			beep := make(chan string, 16)
 
 			tasks := taskrunner.New(ctx, slog.Default())
 
@@ -49,6 +55,18 @@ func main() {
 
 			homeAudio := homeaudioclient.New(homeaudioclient.HomeFn61)
 
+			speak := func(ctx context.Context, message string) {
+				if err := homeAudio.Speak(ctx, message); err != nil {
+					slog.Error("Home audio", "err", err)
+				}
+			}
+
+			resolver := newBackgroundResolver(list, speak, slog.Default())
+
+			tasks.Start("backgroundResolver", func(ctx context.Context) error {
+				return resolver.Run(ctx, backgroundResolverWorkers)
+			})
+
 			if barcodeReaderDevicePath != "" && barcodeReaderDevicePath != "/dev/null" {
 				barcodeReader, close_, err := evdev.Open(barcodeReaderDevicePath)
 				if err != nil {
@@ -59,48 +77,26 @@ func main() {
 				tasks.Start("readBarcodes", func(ctx context.Context) error {
 					err := readBarcodes(ctx, barcodeReader, beep, slog.Default())
 					if err != nil && !errors.Is(err, context.Canceled) {
-						if err := homeAudio.Speak(ctx, "Error with barcode reader"); err != nil {
-							slog.Warn("homeAudio.Speak", "err", err)
-						}
+						speak(ctx, getLocale().Message("audio.error_barcode_reader"))
 					}
 					return err
 				})
 			}
 
+			tasks.Start("outboxReplay", func(ctx context.Context) error {
+				return replayOutbox(ctx, list, slog.Default())
+			})
+
 			tasks.Start("webui", func(ctx context.Context) error {
-				return webUI(ctx, todo, slog.Default())
+				return webUI(ctx, list, resolver, slog.Default())
 			})
 
 			for {
 				select {
 				case err := <-tasks.Done():
 					return err
-				case barcode := <-beep:
-					details, err := handleBeep(ctx, barcode, slog.Default(), todo)
-
-					audioFeedback := func() string {
-						if err != nil {
-							slog.Error("handleBeep", "err", err)
-
-							if errors.Is(err, errItemAlreadyOnShoppingList) {
-								return "Item not added because it was already on the shopping list"
-							} else {
-								return "Error handling scanned barcode"
-							}
-						} else {
-							if details.IsUnrecognizedBarcode() {
-								return "Item added but name is unrecognized"
-							} else if type_ := details.ProductType; type_ != "" {
-								return "Added " + type_
-							} else {
-								return "Item added"
-							}
-						}
-					}()
-
-					if err := homeAudio.Speak(ctx, audioFeedback); err != nil {
-						slog.Error("Home audio", "err", err)
-					}
+				case scanned := <-beep:
+					speak(ctx, handleScan(ctx, scanned, slog.Default(), list, resolver))
 				}
 			}
 		},
@@ -111,11 +107,11 @@ func main() {
 		Short: "Act as though a barcode was scanned. Example input: 6408180733659",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			todo, err := getClient()
+			list, err := getShoppingList()
 			if err != nil {
 				return err
 			}
-			_, err = handleBeep(cmd.Context(), args[0], slog.Default(), todo)
+			_, _, err = handleBeep(cmd.Context(), args[0], slog.Default(), list, nil)
 			return err
 		},
 	})
@@ -125,12 +121,12 @@ func main() {
 		Short: "List misses",
 		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			todo, err := getClient()
+			list, err := getShoppingList()
 			if err != nil {
 				return err
 			}
 
-			misses, err := listMisses(cmd.Context(), todo)
+			misses, err := listMisses(cmd.Context(), list)
 			if err != nil {
 				return err
 			}
@@ -151,20 +147,138 @@ func main() {
 			barcode := args[0]
 			productName := args[1]
 
+			list, err := getShoppingList()
+			if err != nil {
+				return err
+			}
+
+			product := newProductDetails(productName, "")
+			product.Source = productSourceManual
+
+			return recordMissAndStoreToLocalDB(cmd.Context(), barcode, product, list)
+		},
+	})
+
+	app.AddCommand(&cobra.Command{
+		Use:   "sections-migrate",
+		Short: "Move emoji-prefixed tasks to category sections (one-shot migration when enabling TODOIST_SECTIONS)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
 			todo, err := getClient()
 			if err != nil {
 				return err
 			}
 
-			return recordMissAndStoreToLocalDB(cmd.Context(), barcode, newProductDetails(productName, ""), todo)
+			return migrateTasksToSections(cmd.Context(), todo, os.Stdout)
 		},
 	})
 
+	dbCmd := &cobra.Command{
+		Use:   "db",
+		Short: "Local barcode DB maintenance",
+	}
+
+	enrichOpts := enrichOptions{Interval: 4 * time.Second}
+	dbEnrichCmd := &cobra.Command{
+		Use:   "enrich",
+		Short: "Use AI to fill in missing product types and categories of entries in the local DB",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			list, err := getShoppingList()
+			if err != nil {
+				return err
+			}
+
+			return dbEnrich(cmd.Context(), enrichOpts, list, os.Stdin, os.Stdout, slog.Default())
+		},
+	}
+	dbEnrichCmd.Flags().BoolVarP(&enrichOpts.ApproveAll, "yes", "y", enrichOpts.ApproveAll, "Apply all proposed changes without asking")
+	dbEnrichCmd.Flags().BoolVarP(&enrichOpts.DryRun, "dry-run", "", enrichOpts.DryRun, "Only show the proposed changes")
+	dbEnrichCmd.Flags().DurationVarP(&enrichOpts.Interval, "interval", "", enrichOpts.Interval, "Minimum time between AI requests (rate limiting)")
+	dbEnrichCmd.Flags().IntVarP(&enrichOpts.Limit, "limit", "", enrichOpts.Limit, "Process at most this many entries (0 = all)")
+	dbEnrichCmd.Flags().BoolVarP(&enrichOpts.Restart, "restart", "", enrichOpts.Restart, "Ignore progress of a previous (interrupted) run")
+	dbCmd.AddCommand(dbEnrichCmd)
+	app.AddCommand(dbCmd)
+
+	evalReplayAI := false
+	evalCmd := &cobra.Command{
+		Use:   "eval [dataset.json]",
+		Short: "Evaluate product naming quality against a dataset of recorded web search results",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dataset, err := loadEvalDataset(args[0])
+			if err != nil {
+				return err
+			}
+
+			results, err := evaluate(cmd.Context(), dataset, evalReplayAI, slog.Default())
+			if err != nil {
+				return err
+			}
+
+			printEvalReport(results, os.Stdout)
+
+			return nil
+		},
+	}
+	evalCmd.Flags().BoolVarP(&evalReplayAI, "replay-ai", "", evalReplayAI, "Replay AI answers recorded in the dataset instead of asking the configured AI provider")
+	app.AddCommand(evalCmd)
+
 	cli.Execute(app)
 }
 
-func handleBeep(ctx context.Context, barcode string, logger *slog.Logger, todo *todoist.Client) (*productDetails, error) {
-	withErr := func(err error) (*productDetails, error) { return nil, fmt.Errorf("handleBeep: %w", err) }
+// handles anything scanned with the barcode reader. returns audio feedback for the user.
+func handleScan(ctx context.Context, scanned string, logger *slog.Logger, list ShoppingList, resolver *backgroundResolver) string {
+	if _, isDigitalLink := productpage.ParseGS1DigitalLink(scanned); isURL(scanned) && !isDigitalLink { // recipe QR code?
+		result, err := importRecipeFromURL(ctx, scanned, list, logger)
+		switch {
+		case err == nil:
+			return audioFeedbackForRecipeImport(result)
+		case errors.Is(err, recipe.ErrNoRecipe):
+			// not a recipe. handle like any other scan (it might be a link to a product page).
+		default:
+			logger.Error("importRecipeFromURL", "err", err)
+			return getLocale().Message("audio.error_importing_recipe")
+		}
+	}
+
+	scanMode, err := getScanMode()
+	if err != nil {
+		logger.Error("getScanMode", "err", err)
+		return getLocale().Message("audio.error_handling_scan")
+	}
+
+	if scanMode == scanModeConsume {
+		barcode := scanned
+		if gtin, isDigitalLink := productpage.ParseGS1DigitalLink(scanned); isDigitalLink {
+			barcode = gtin
+		}
+
+		if err := consumeFromGrocy(ctx, barcode); err != nil {
+			logger.Error("consumeFromGrocy", "err", err)
+
+			if message, isServiceError := audioFeedbackForServiceError(err); isServiceError {
+				return message
+			}
+			return getLocale().Message("audio.error_consuming")
+		}
+
+		return getLocale().Message("audio.consumed")
+	}
+
+	details, resolvingInBackground, err := handleBeep(ctx, scanned, logger, list, resolver)
+	if err != nil {
+		logger.Error("handleBeep", "err", err)
+	}
+
+	return audioFeedbackForBeep(details, resolvingInBackground, err)
+}
+
+// if resolver is given, barcodes not in our local DB are added to the shopping list as placeholders
+// and their product details are resolved in the background (because that can take several seconds).
+// otherwise they are resolved before adding to the shopping list.
+func handleBeep(ctx context.Context, barcode string, logger *slog.Logger, list ShoppingList, resolver *backgroundResolver) (_ *productDetails, resolvingInBackground bool, _ error) {
+	withErr := func(err error) (*productDetails, bool, error) { return nil, false, fmt.Errorf("handleBeep: %w", err) }
 
 	// better reload this on every beep so that if DB has been updated, the changes are reflected
 	db, err := loadDB()
@@ -172,22 +286,28 @@ func handleBeep(ctx context.Context, barcode string, logger *slog.Logger, todo *
 		return withErr(err)
 	}
 
+	if _, found := localDBresolveProductByBarcode(barcode, db); !found && resolver != nil {
+		placeholder := newProductDetails(taskNameForUnnamedBarcode(barcode), "")
+
+		slog.Info("adding placeholder", "barcode", barcode)
+
+		// if the placeholder went to the outbox, we still resolve. the rename gets queued after the add.
+		if err := addProductNameToShoppingList(ctx, barcode, placeholder, createDescriptionMarkdown(barcode, placeholder), list); err != nil && !errors.Is(err, errSavedToOutbox) {
+			return withErr(err)
+		}
+
+		// when resolved, the placeholder gets renamed
+		return &placeholder, resolver.Enqueue(barcode), nil
+	}
+
 	details, err := func() (productDetails, error) {
-		details, err := resolveProductDetailsByBarcode(ctx, barcode, db, todo, logger)
+		details, err := resolveProductDetailsByBarcode(ctx, barcode, db, list, logger)
 		if err != nil {
 			slog.Error("handleBeep: unable to resolve", "barcode", barcode, "err", err)
 
 			return newProductDetails(taskNameForUnnamedBarcode(barcode), ""), nil
 		} else { // found
-			details.LastScanned = Pointer(time.Now().UTC())
-
-			(*db)[barcode] = *details
-
-			if err := saveDB(*db); err != nil {
-				return *details, err
-			}
-
-			return *details, nil
+			return recordLastScanned(barcode, *details)
 		}
 	}()
 	if err != nil {
@@ -199,47 +319,168 @@ func handleBeep(ctx context.Context, barcode string, logger *slog.Logger, todo *
 		"ProductName", details.Name,
 	)
 
-	if err := addProductNameToShoppingList(ctx, details, createDescriptionMarkdown(barcode), todo); err != nil {
+	if err := addProductNameToShoppingList(ctx, barcode, details, createDescriptionMarkdown(barcode, details), list); err != nil {
 		return withErr(err)
 	}
 
-	return &details, nil
+	return &details, false, nil
 }
 
-func recordMissAndStoreToLocalDB(ctx context.Context, barcode string, product productDetails, todo *todoist.Client) error {
-	projectID, err := getTodoistProjectID()
+func recordLastScanned(barcode string, details productDetails) (productDetails, error) {
+	details.LastScanned = Pointer(time.Now().UTC())
+
+	return details, updateDB(func(db LocalDB) error {
+		db[barcode] = details
+		return nil
+	})
+}
+
+// what to tell the user (via audio) after having handled a scanned barcode
+func audioFeedbackForBeep(details *productDetails, resolvingInBackground bool, err error) string {
+	loc := getLocale()
+
 	if err != nil {
-		return err
+		if errors.Is(err, errItemAlreadyOnShoppingList) {
+			return loc.Message("audio.already_on_list")
+		} else if errors.Is(err, errSavedToOutbox) {
+			return loc.Message("audio.saved_to_outbox")
+		} else if message, isServiceError := audioFeedbackForServiceError(err); isServiceError {
+			return message
+		} else {
+			return loc.Message("audio.error_handling_scan")
+		}
+	} else {
+		if resolvingInBackground {
+			return loc.Message("audio.item_added_looking_up")
+		} else if details.IsUnrecognizedBarcode() {
+			return loc.Message("audio.item_added_unrecognized")
+		} else if type_ := details.ProductType; type_ != "" {
+			return loc.Message("audio.added_product_type", type_)
+		} else {
+			return loc.Message("audio.item_added")
+		}
 	}
+}
 
-	existingTasks, err := todo.TasksByProject(ctx, projectID, time.Now())
-	if err != nil {
-		return err
+// more accurate than a generic error message when an external service (shopping list, web search, AI)
+// is the reason we failed
+func audioFeedbackForServiceError(err error) (string, bool) {
+	loc := getLocale()
+
+	switch {
+	case errors.Is(err, resilienthttp.ErrRateLimited):
+		return loc.Message("audio.error_rate_limited"), true
+	case errors.Is(err, resilienthttp.ErrTimeout):
+		return loc.Message("audio.error_timeout"), true
+	case errors.Is(err, resilienthttp.ErrUnavailable):
+		return loc.Message("audio.error_service_unavailable"), true
+	default:
+		return "", false
 	}
+}
 
-	taskNameForUnnamed := taskNameForUnnamedBarcode(barcode)
+// stores product details to the local DB and renames tasks on the shopping list that refer to
+// the product by its previous name (unrecognized barcode, or previous version of product details)
+func recordMissAndStoreToLocalDB(ctx context.Context, barcode string, product productDetails, list ShoppingList) error {
+	return updateProductDetails(ctx, barcode, func(_ productDetails, _ bool) (productDetails, error) { return product, nil }, list)
+}
+
+// like `recordMissAndStoreToLocalDB()`, but the new details are derived from the stored ones (zero value if
+// not stored). this happens inside the DB update, so changes made meanwhile (like scan times) are not lost.
+func updateProductDetails(ctx context.Context, barcode string, update func(previous productDetails, found bool) (productDetails, error), list ShoppingList) error {
+	previousTaskNames := []string{taskNameForUnnamedBarcode(barcode)}
+	removeLabels := []string{}
+	var product productDetails
 
-	// rename current tasks that refer to this unnamed task
-	for _, missing := range lo.Filter(existingTasks, func(t todoist.Task, _ int) bool { return t.Content == taskNameForUnnamed }) {
-		missing.Content = product.Name
+	// now next time we will remember the proper name for this
+	if err := updateDB(func(db LocalDB) error {
+		previous, found := db[barcode]
 
-		if err := todo.UpdateTask(ctx, missing); err != nil {
+		var err error
+		product, err = update(previous, found)
+		if err != nil {
 			return err
 		}
+
+		if found {
+			previousTaskNames = append(previousTaskNames, taskNameForProduct(previous))
+			removeLabels = staleLabels(previous, product)
+		}
+
+		db[barcode] = product
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return renameTasks(ctx, previousTaskNames, taskSpecForProduct(product, createDescriptionMarkdown(barcode, product)), removeLabels, list)
+}
+
+// the product was misidentified. forget it so it will be resolved again on next scan.
+func rejectProductDetails(ctx context.Context, barcode string, list ShoppingList) error {
+	var previous productDetails
+
+	if err := updateDB(func(db LocalDB) error {
+		var found bool
+		previous, found = db[barcode]
+		if !found {
+			return fmt.Errorf("rejectProductDetails: barcode not found: %s", barcode)
+		}
+
+		delete(db, barcode)
+		return nil
+	}); err != nil {
+		return err
 	}
 
-	db, err := loadDB()
+	unnamed := newProductDetails(taskNameForUnnamedBarcode(barcode), "")
+
+	return renameTasks(ctx, []string{taskNameForProduct(previous)}, taskSpecForProduct(unnamed, createDescriptionMarkdown(barcode, unnamed)), labelsForProduct(previous), list)
+}
+
+// updates items named any of `fromNames` to match `spec` (except order). if the shopping list is
+// unreachable, the update is saved to the outbox to be retried later.
+func renameTasks(ctx context.Context, fromNames []string, spec taskSpec, removeLabels []string, list ShoppingList) error {
+	return withOutboxFallback(renameTasksInternal(ctx, fromNames, spec, removeLabels, list), outboxEntry{
+		Op:           outboxOpRename,
+		TaskName:     spec.Name,
+		Description:  spec.Description,
+		Category:     spec.Category,
+		Labels:       spec.Labels,
+		RemoveLabels: removeLabels,
+		RenameFrom:   fromNames,
+	})
+}
+
+func renameTasksInternal(ctx context.Context, fromNames []string, spec taskSpec, removeLabels []string, list ShoppingList) error {
+	isRenamed := func(item ShoppingListItem) bool { return slices.Contains(fromNames, item.Name) }
+
+	itemsToRename, err := shoppingListItems(ctx, isRenamed, false, list)
 	if err != nil {
 		return err
 	}
+	if len(itemsToRename) == 0 { // maybe the item was added elsewhere and our cached copy doesn't know it yet
+		itemsToRename, err = shoppingListItems(ctx, isRenamed, true, list)
+		if err != nil {
+			return err
+		}
+	}
 
-	(*db)[barcode] = product
+	for _, item := range itemsToRename {
+		item.Name = spec.Name
+		item.Description = spec.Description
+		item.Category = spec.Category
+		item.Labels = updateLabels(item.Labels, spec.Labels, removeLabels)
 
-	// now next time we will remember the proper name for this
-	return saveDB(*db)
+		if err := list.Rename(ctx, item); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func resolveProductDetailsByBarcode(ctx context.Context, barcode string, resolveDB *LocalDB, todo *todoist.Client, logger *slog.Logger) (*productDetails, error) {
+func resolveProductDetailsByBarcode(ctx context.Context, barcode string, resolveDB *LocalDB, list ShoppingList, logger *slog.Logger) (*productDetails, error) {
 	withErr := func(err error) (*productDetails, error) {
 		return nil, fmt.Errorf("resolveProductDetailsByBarcode: %w", err)
 	}
@@ -247,15 +488,44 @@ func resolveProductDetailsByBarcode(ctx context.Context, barcode string, resolve
 	if product, found := localDBresolveProductByBarcode(barcode, resolveDB); found {
 		return &product, nil
 	}
-	slog.Info("localDBresolveProductByBarcode: not found. continuing with web search")
+	slog.Info("localDBresolveProductByBarcode: not found. continuing with Grocy / web search")
+
+	if !isURL(barcode) { // before the store-internal barcode checks, as the user might have taught those to Grocy
+		grocyClient, err := getGrocy()
+		if err != nil {
+			return withErr(err)
+		}
+
+		product, err := grocyResolveProductByBarcode(ctx, barcode, grocyClient)
+		switch {
+		case err != nil: // not critical, as web search might still find it
+			logger.Error("grocyResolveProductByBarcode", "err", err)
+		case product != nil:
+			if err := recordMissAndStoreToLocalDB(ctx, barcode, *product, list); err != nil {
+				logger.Error("recordMissAndStoreToLocalDB", "err", err)
+			}
+
+			return product, nil
+		}
+	}
 
 	// https://en.wikipedia.org/wiki/List_of_GS1_country_codes
 	if strings.HasPrefix(barcode, "2") {
 		return withErr(errors.New("barcode begins with 2 which implies store-internal barcode - bailing out"))
 	}
 
-	if strings.HasPrefix(barcode, "https:") || strings.HasPrefix(barcode, "http:") {
-		return withErr(errors.New("barcode encodes an (unrecognized) URL - bailing out"))
+	if isURL(barcode) { // QR code
+		product, err := resolveProductDetailsByURL(ctx, barcode, resolveDB, list, logger)
+		if err != nil {
+			return withErr(err)
+		}
+
+		// remember by the URL as well (also renames a possible placeholder for it)
+		if err := recordMissAndStoreToLocalDB(ctx, barcode, *product, list); err != nil {
+			logger.Error("recordMissAndStoreToLocalDB", "err", err)
+		}
+
+		return product, nil
 	}
 
 	if l := len(barcode); l < 10 { // EAN should be 13. UPC should be 12.
@@ -264,7 +534,7 @@ func resolveProductDetailsByBarcode(ctx context.Context, barcode string, resolve
 		return withErr(fmt.Errorf("length of barcode so short (%d) it implies store-internal barcode - bailing out", l))
 	}
 
-	searchEngine, err := googlesearch.New()
+	searchEngine, err := getSearcher()
 	if err != nil {
 		return withErr(err)
 	}
@@ -274,27 +544,95 @@ func resolveProductDetailsByBarcode(ctx context.Context, barcode string, resolve
 		return withErr(err)
 	}
 
-	if len(barcodeSearchResults.Items) == 0 { // next steps needs there to be search results
+	if len(barcodeSearchResults) == 0 { // next steps needs there to be search results
 		return withErr(fmt.Errorf("no web search results for barcode '%s'", barcode))
 	}
 
-	searchResultTitles := lo.Map(barcodeSearchResults.Items, func(result googlesearch.Item, _ int) string { return result.Title })
+	product := productDetailsFromSearchResults(ctx, barcodeSearchResults, useAIAssistantToGuessProductDetailsFromSearchResults, logger)
 
-	product := func() *productDetails {
-		link := barcodeSearchResults.Items[0].Link
-		result, err := useAIAssistantToGuessProductDetailsFromSearchResults(ctx, searchResultTitles, link, logger)
-		if err != nil {
-			productNameGuess := strings.Split(searchResultTitles[0], " - ")[0]
-			slog.Warn("AI guess of product details failed; falling back to first search result", "err", err, "fallback", productNameGuess)
-			return Pointer(newProductDetails(productNameGuess, link))
+	if err := recordMissAndStoreToLocalDB(ctx, barcode, product, list); err != nil {
+		// this is not critical error in context of this function's task
+		logger.Error("recordMissAndStoreToLocalDB", "err", err)
+	}
+
+	// so Grocy doesn't need to ask us next time
+	pushToGrocyIfAIResolved(ctx, barcode, product, logger)
+
+	return &product, nil
+}
+
+type productGuesser func(ctx context.Context, searchResults []websearch.Result, link string, logger *slog.Logger) (*productDetails, error)
+
+// AI's guess of product details, falling back to the first search result if AI fails.
+// searchResults must not be empty.
+func productDetailsFromSearchResults(ctx context.Context, searchResults []websearch.Result, guess productGuesser, logger *slog.Logger) productDetails {
+	link := searchResults[0].Link
+
+	result, err := guess(ctx, searchResults, link, logger)
+	if err != nil {
+		productNameGuess := strings.Split(searchResults[0].Title, " - ")[0]
+		logger.Warn("AI guess of product details failed; falling back to first search result", "err", err, "fallback", productNameGuess)
+		fallback := newProductDetails(productNameGuess, link)
+		fallback.Source = productSourceWebSearch
+		fallback.NeedsReview = true // just a guess
+		return fallback
+	}
+
+	return *result
+}
+
+// product packages' QR codes are either GS1 Digital Links (which contain the barcode number) or links
+// to the product's web page (possibly through an URL shortener)
+func resolveProductDetailsByURL(ctx context.Context, link string, resolveDB *LocalDB, list ShoppingList, logger *slog.Logger) (*productDetails, error) {
+	withErr := func(err error) (*productDetails, error) {
+		return nil, fmt.Errorf("resolveProductDetailsByURL: %w", err)
+	}
+
+	// no need to fetch anything if we can see the GTIN directly
+	if gtin, isDigitalLink := productpage.ParseGS1DigitalLink(link); isDigitalLink {
+		return resolveProductDetailsByBarcode(ctx, gtin, resolveDB, list, logger)
+	}
+
+	fetched, err := fetchPage(ctx, link) // likely already fetched when this was tried as a recipe
+	if err != nil {
+		return withErr(err)
+	}
+
+	page, err := productpage.FromPage(fetched)
+	if err != nil {
+		return withErr(err)
+	}
+
+	if page.Name == "" { // redirected to a GS1 Digital Link, or the page only told us the GTIN
+		if page.GTIN == "" {
+			return withErr(errors.New("UNEXPECTED: page has neither name nor GTIN"))
 		}
 
-		return result
-	}()
+		return resolveProductDetailsByBarcode(ctx, page.GTIN, resolveDB, list, logger)
+	}
 
-	if err := recordMissAndStoreToLocalDB(ctx, barcode, *product, todo); err != nil {
-		// this is not critical error in context of this function's task
-		logger.Error("recordMissAndStoreToLocalDB", "err", err)
+	// the page tells the name, but we need AI for type and category. the page's metadata
+	// is in effect one very good search result.
+	pageAsSearchResult := websearch.Result{
+		Title:   page.Name,
+		Snippet: page.Description,
+		Link:    page.URL,
+		Image:   page.Image,
+		Product: &websearch.Product{
+			Name:        page.Name,
+			Description: page.Description,
+			Brand:       page.Brand,
+			Image:       page.Image,
+		},
+	}
+
+	product, err := useAIAssistantToGuessProductDetailsFromSearchResults(ctx, []websearch.Result{pageAsSearchResult}, page.URL, logger)
+	if err != nil {
+		logger.Warn("AI guess of product details failed; falling back to product page's name", "err", err)
+
+		fallback := newProductDetails(page.Name, page.URL)
+		fallback.NeedsReview = true // type and category unknown
+		return &fallback, nil
 	}
 
 	return product, nil
@@ -304,52 +642,110 @@ var (
 	errItemAlreadyOnShoppingList = errors.New("requested productName already on the list")
 )
 
-func addProductNameToShoppingList(ctx context.Context, product productDetails, description string, todo *todoist.Client) error {
-	projectID, err := getTodoistProjectID()
-	if err != nil {
-		return err
-	}
+// if the shopping list is unreachable, the addition is saved to the outbox to be retried later
+func addProductNameToShoppingList(ctx context.Context, barcode string, product productDetails, description string, list ShoppingList) error {
+	spec := taskSpecForProduct(product, description)
+	spec.Barcode = barcode
 
-	category, categoryIdx := resolveProductCategory(product.ProductCategory)
+	spec.Order = func() int {
+		if useSections() { // sections do the grouping
+			return 0
+		}
 
-	taskName, order := func() (string, int) {
-		if category != nil {
-			return fmt.Sprintf("%s %s", category.Emoji, product.Name), 10000 + (categoryIdx * 100)
+		if category, categoryIdx := resolveProductCategory(product.ProductCategory); category != nil {
+			return 10000 + (categoryIdx * 100)
 		} else {
-			return product.Name, 0
+			return 0
 		}
 	}()
 
-	existingTasks, err := todo.TasksByProject(ctx, projectID, time.Now())
+	return withOutboxFallback(addTaskToShoppingList(ctx, spec, list), outboxEntry{
+		Op:          outboxOpAdd,
+		Barcode:     spec.Barcode,
+		TaskName:    spec.Name,
+		Description: spec.Description,
+		Order:       spec.Order,
+		Category:    spec.Category,
+		Labels:      spec.Labels,
+	})
+}
+
+// what a product's task on the shopping list looks like
+type taskSpec struct {
+	Name        string
+	Description string
+	Order       int // only used when creating
+	Category    string
+	Labels      []string
+	Barcode     string // "" if not a scanned product
+}
+
+func taskSpecForProduct(product productDetails, description string) taskSpec {
+	return taskSpec{
+		Name:        taskNameForProduct(product),
+		Description: description,
+		Category:    product.ProductCategory,
+		Labels:      labelsForProduct(product),
+	}
+}
+
+func addTaskToShoppingList(ctx context.Context, spec taskSpec, list ShoppingList) error {
+	isSame := func(item ShoppingListItem) bool { return item.Name == spec.Name }
+
+	sameItems, err := shoppingListItems(ctx, isSame, false, list)
 	if err != nil {
 		return err
 	}
+	if len(sameItems) > 0 { // maybe it was already checked off and our cached copy doesn't know it yet
+		sameItems, err = shoppingListItems(ctx, isSame, true, list)
+		if err != nil {
+			return err
+		}
+	}
 
-	if _, alreadyOnList := lo.Find(existingTasks, func(t todoist.Task) bool { return t.Content == taskName }); alreadyOnList {
+	if len(sameItems) > 0 {
 		return errItemAlreadyOnShoppingList
 	}
 
-	return todo.CreateTask(ctx, todoist.Task{
-		Content:     taskName,
-		Description: description,
-		ProjectID:   projectID,
-		Order:       order,
+	created, err := list.Add(ctx, ShoppingListItem{
+		Name:        spec.Name,
+		Description: spec.Description,
+		Category:    spec.Category,
+		Labels:      spec.Labels,
+		Order:       spec.Order,
+		Barcode:     spec.Barcode,
 	})
+	if err != nil {
+		return err
+	}
+
+	if spec.Barcode != "" { // for mapping purchases back to the barcode
+		if err := recordTaskOnList(spec.Barcode, created.ID); err != nil {
+			slog.Error("addTaskToShoppingList: recordTaskOnList", "err", err) // the add itself succeeded
+		}
+	}
+
+	return nil
 }
 
-func listMisses(ctx context.Context, todo *todoist.Client) ([]string, error) {
-	projectID, err := getTodoistProjectID()
+// items on the shopping list that match. `fresh` bypasses the list's cache (if it has one).
+func shoppingListItems(ctx context.Context, match func(ShoppingListItem) bool, fresh bool, list ShoppingList) ([]ShoppingListItem, error) {
+	items, err := list.Items(ctx, fresh)
 	if err != nil {
 		return nil, err
 	}
 
-	existingTasks, err := todo.TasksByProject(ctx, projectID, time.Now())
+	return lo.Filter(items, func(item ShoppingListItem, _ int) bool { return match(item) }), nil
+}
+
+func listMisses(ctx context.Context, list ShoppingList) ([]string, error) {
+	items, err := list.Items(ctx, false)
 	if err != nil {
 		return nil, err
 	}
 
-	return lo.FilterMap(existingTasks, func(t todoist.Task, _ int) (string, bool) {
-		match := identifyMissRe.FindStringSubmatch(t.Content)
+	return lo.FilterMap(items, func(item ShoppingListItem, _ int) (string, bool) {
+		match := identifyMissRe.FindStringSubmatch(item.Name)
 		if match == nil {
 			return "", false
 		}
@@ -358,20 +754,50 @@ func listMisses(ctx context.Context, todo *todoist.Client) ([]string, error) {
 	}), nil
 }
 
+const (
+	// visual marker on the shopping list for products whose details a human should check
+	needsReviewMarker = " (?)"
+)
+
+func taskNameForProduct(product productDetails) string {
+	taskName := product.ShortName()
+
+	if category, _ := resolveProductCategory(product.ProductCategory); category != nil && !useSections() {
+		taskName = fmt.Sprintf("%s %s", category.Emoji, taskName)
+	}
+
+	if product.NeedsReview {
+		taskName += needsReviewMarker
+	}
+
+	return taskName
+}
+
 func taskNameForUnnamedBarcode(barcode string) string {
 	return fmt.Sprintf("unrecognized barcode[%s]", barcode)
 }
 
 // use as description (which supports Markdown) a link to the item, so we have access to all the details
-// (like barcode, web search etc.) in the task
-func createDescriptionMarkdown(barcode string) string {
+// (like barcode, web search etc.) in the task. if the task is named with the short display name, the
+// full product name is in the description.
+func createDescriptionMarkdown(barcode string, product productDetails) string {
 	// searchURL := fmt.Sprintf("https://google.com/search?q=%s", url.QueryEscape(barcode))
 	baseURL := os.Getenv("WEBAPP_BASEURL")
 	linkToWebui := baseURL + appHomeRoute + "item/" + url.PathEscape(barcode)
-	return fmt.Sprintf("[Details](%s)", linkToWebui)
+	detailsLink := fmt.Sprintf("[Details](%s)", linkToWebui)
+
+	if product.ShortName() != product.Name {
+		return product.Name + "\n\n" + detailsLink
+	} else {
+		return detailsLink
+	}
 }
 
-var identifyMissRe = regexp.MustCompile(`^unrecognized barcode\[([0-9]+)\]$`)
+func isURL(scanned string) bool {
+	return strings.HasPrefix(scanned, "https:") || strings.HasPrefix(scanned, "http:")
+}
+
+var identifyMissRe = regexp.MustCompile(`^unrecognized barcode\[([^\]]+)\]$`)
 
 func getClient() (*todoist.Client, error) {
 	tok, err := osutil.GetenvRequired("TODOIST_TOKEN")
@@ -379,6 +805,41 @@ func getClient() (*todoist.Client, error) {
 	return todoist.NewClient(tok), err
 }
 
+// web search providers are tried in order. if one fails (or has no results) we fall back to the next one.
+func getSearcher() (websearch.Searcher, error) {
+	providers := strings.Split(cmp.Or(os.Getenv("WEB_SEARCH_PROVIDERS"), "google"), ",")
+
+	searchers := []websearch.Searcher{}
+	for _, provider := range providers {
+		provider = strings.TrimSpace(provider)
+
+		searcher, err := func() (websearch.Searcher, error) {
+			switch provider {
+			case "google":
+				return websearch.NewGoogle()
+			case "searxng":
+				baseURL, err := osutil.GetenvRequired("SEARXNG_BASEURL")
+				return websearch.NewSearXNG(baseURL), err
+			case "brave":
+				apiKey, err := osutil.GetenvRequired("BRAVE_SEARCH_API_KEY")
+				return websearch.NewBrave(apiKey), err
+			case "bing":
+				apiKey, err := osutil.GetenvRequired("BING_SEARCH_API_KEY")
+				return websearch.NewBing(apiKey), err
+			default:
+				return nil, fmt.Errorf("unsupported web search provider: %s", provider)
+			}
+		}()
+		if err != nil {
+			return nil, err
+		}
+
+		searchers = append(searchers, meteredSearcher{searcher, provider})
+	}
+
+	return websearch.Fallback(searchers...), nil
+}
+
 func getTodoistProjectID() (string, error) {
 	return osutil.GetenvRequired("TODOIST_PROJECT_ID")
 }
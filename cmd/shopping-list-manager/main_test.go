@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/function61/gokit/encoding/jsonfile"
+	"github.com/function61/gokit/testing/assert"
+	"github.com/joonas-fi/shopping-list-manager/pkg/resilienthttp"
+)
+
+func TestAudioFeedbackForBeep(t *testing.T) {
+	milk := &productDetails{Name: "Valio Kevytmaito 1 l", ProductType: "Milk"}
+	placeholder := &productDetails{Name: taskNameForUnnamedBarcode("6408430000258")}
+
+	assert.Equal(t, audioFeedbackForBeep(milk, false, nil), "Added Milk")
+	assert.Equal(t, audioFeedbackForBeep(placeholder, true, nil), "Item added. Looking up its name")
+	assert.Equal(t, audioFeedbackForBeep(placeholder, false, nil), "Item added but name is unrecognized")
+	assert.Equal(t, audioFeedbackForBeep(nil, false, fmt.Errorf("handleBeep: %w", errItemAlreadyOnShoppingList)), "Item not added because it was already on the shopping list")
+	assert.Equal(t, audioFeedbackForBeep(nil, false, fmt.Errorf("handleBeep: %w", errSavedToOutbox)), "Shopping list unreachable. Saved, will sync later")
+	assert.Equal(t, audioFeedbackForBeep(nil, false, fmt.Errorf("handleBeep: %w", &resilienthttp.Error{Service: "googlesearch", Kind: resilienthttp.ErrRateLimited, Err: errors.New("429 Too Many Requests")})), "Too many requests to an online service. Try again in a moment")
+	assert.Equal(t, audioFeedbackForBeep(nil, false, errors.New("something else")), "Error handling scanned barcode")
+}
+
+func TestScanShortLinkToNonOKPage(t *testing.T) {
+	ctx := context.Background()
+	chdirTemp(t)
+	t.Setenv("LOCALE", "en")
+
+	assert.Ok(t, jsonfile.Write(localDBName, LocalDB{
+		"6408430000258": productDetails{Name: "Valio Kevytmaito 1 l", ProductType: "Milk"},
+	}))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/short":
+			http.Redirect(w, r, "/01/06408430000258", http.StatusFound)
+		case "/bot-blocked":
+			http.Error(w, "no bots", http.StatusForbidden)
+		default: // the Digital Link page doesn't need to exist
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	list := &memoryShoppingList{}
+
+	assert.Equal(t, handleScan(ctx, srv.URL+"/short", slog.Default(), list, nil), "Added Milk")
+	assert.Equal(t, list.String(), "1: Valio Kevytmaito 1 l [] []")
+
+	// not a recipe either, so it's handled like any unrecognized product
+	assert.Equal(t, handleScan(ctx, srv.URL+"/bot-blocked", slog.Default(), list, nil), "Item added but name is unrecognized")
+}
@@ -0,0 +1,207 @@
+package main
+
+// Durable queue for shopping list mutations that failed because the shopping list was unreachable
+// (internet down, Todoist outage etc.). They are replayed in the background once it is reachable again.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/function61/gokit/app/backoff"
+	"github.com/function61/gokit/encoding/jsonfile"
+	"github.com/function61/gokit/net/http/ezhttp"
+	"github.com/joonas-fi/shopping-list-manager/pkg/resilienthttp"
+)
+
+const (
+	outboxName = "outbox.json"
+
+	outboxOpAdd    = "add"
+	outboxOpRename = "rename"
+
+	outboxPollInterval = 1 * time.Minute
+)
+
+var (
+	errSavedToOutbox = errors.New("shopping list unreachable; saved to outbox, will sync later")
+
+	// serializes read-modify-write cycles of the outbox
+	outboxMu sync.Mutex
+)
+
+type outboxEntry struct {
+	Op           string    `json:"op"`                // outboxOpAdd | outboxOpRename
+	TaskName     string    `json:"task_name"`         // add: name of task to create. rename: new name
+	Barcode      string    `json:"barcode,omitempty"` // add
+	Description  string    `json:"description,omitempty"`
+	Order        int       `json:"order,omitempty"`    // add
+	Category     string    `json:"category,omitempty"` // for the section (in section mode)
+	Labels       []string  `json:"labels,omitempty"`
+	RemoveLabels []string  `json:"remove_labels,omitempty"` // rename
+	RenameFrom   []string  `json:"rename_from,omitempty"`   // rename
+	Queued       time.Time `json:"queued"`
+}
+
+// if `err` is a transient error, saves entry to the outbox and returns `errSavedToOutbox`
+func withOutboxFallback(err error, entry outboxEntry) error {
+	if err == nil || !isTransientError(err) {
+		return err
+	}
+
+	entry.Queued = time.Now().UTC()
+
+	if errOutbox := updateOutbox(func(entries []outboxEntry) ([]outboxEntry, error) {
+		return append(entries, entry), nil
+	}); errOutbox != nil {
+		return errors.Join(err, errOutbox)
+	}
+
+	return fmt.Errorf("%w: %w", errSavedToOutbox, err)
+}
+
+// errors that are likely to go away if we try again later. anything else (bad config, invalid
+// response, item not found..) is permanent, as retrying it would block the rest of the outbox.
+func isTransientError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	if errors.Is(err, resilienthttp.ErrTimeout) || errors.Is(err, resilienthttp.ErrUnavailable) || errors.Is(err, resilienthttp.ErrRateLimited) || errors.Is(err, resilienthttp.ErrCircuitOpen) {
+		return true
+	}
+
+	var statusErr *ezhttp.ResponseStatusError
+	if errors.As(err, &statusErr) {
+		code := statusErr.StatusCode()
+		return code >= 500 || code == http.StatusTooManyRequests || code == http.StatusRequestTimeout
+	}
+
+	// transport-level errors (DNS, connection refused, timeouts)
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// replays outbox entries (oldest first) with backoff, until `ctx` is canceled
+func replayOutbox(ctx context.Context, list ShoppingList, logger *slog.Logger) error {
+	newBackoff := func() backoff.Func { return backoff.ExponentialWithCappedMax(5*time.Second, 10*time.Minute) }
+
+	retryBackoff := newBackoff()
+
+	wait := outboxPollInterval
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+
+		if err := replayOutboxOnce(ctx, list, logger); err != nil {
+			wait = retryBackoff()
+			logger.Warn("replayOutbox: still unreachable", "err", err, "next_attempt_in", wait)
+		} else {
+			retryBackoff = newBackoff()
+			wait = outboxPollInterval
+		}
+	}
+}
+
+// returns error only for a transient failure (replaying should be tried again later)
+func replayOutboxOnce(ctx context.Context, list ShoppingList, logger *slog.Logger) error {
+	for {
+		entries, err := loadOutbox()
+		if err != nil {
+			return err
+		}
+
+		if len(entries) == 0 {
+			return nil
+		}
+
+		entry := entries[0]
+
+		if err := replayOutboxEntry(ctx, entry, list); err != nil {
+			if isTransientError(err) {
+				return err
+			}
+
+			// retrying a permanent failure would just block the rest of the outbox
+			logger.Error("replayOutbox: dropping entry", "op", entry.Op, "task", entry.TaskName, "err", err)
+		} else {
+			logger.Info("replayOutbox: synced", "op", entry.Op, "task", entry.TaskName)
+		}
+
+		// remove what we just processed. there might've been new entries added concurrently.
+		if err := updateOutbox(func(entries []outboxEntry) ([]outboxEntry, error) {
+			if len(entries) == 0 {
+				return entries, nil
+			}
+			return entries[1:], nil
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+func replayOutboxEntry(ctx context.Context, entry outboxEntry, list ShoppingList) error {
+	switch entry.Op {
+	case outboxOpAdd:
+		// dedupe against the list state at replay time, since someone might've added it meanwhile
+		err := addTaskToShoppingList(ctx, entry.spec(), list)
+		if errors.Is(err, errItemAlreadyOnShoppingList) {
+			return nil
+		}
+		return err
+	case outboxOpRename:
+		return renameTasksInternal(ctx, entry.RenameFrom, entry.spec(), entry.RemoveLabels, list)
+	default:
+		return fmt.Errorf("unsupported op: %s", entry.Op)
+	}
+}
+
+func (e outboxEntry) spec() taskSpec {
+	return taskSpec{
+		Name:        e.TaskName,
+		Description: e.Description,
+		Order:       e.Order,
+		Category:    e.Category,
+		Labels:      e.Labels,
+		Barcode:     e.Barcode,
+	}
+}
+
+func loadOutbox() ([]outboxEntry, error) {
+	entries := []outboxEntry{}
+	if err := jsonfile.ReadDisallowUnknownFields(outboxName, &entries); err != nil {
+		if errors.Is(err, fs.ErrNotExist) { // allowed to not exist - nothing queued then
+			return entries, nil
+		} else { // some other error
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+func updateOutbox(modify func(entries []outboxEntry) ([]outboxEntry, error)) error {
+	outboxMu.Lock()
+	defer outboxMu.Unlock()
+
+	entries, err := loadOutbox()
+	if err != nil {
+		return err
+	}
+
+	entries, err = modify(entries)
+	if err != nil {
+		return err
+	}
+
+	return jsonfile.Write(outboxName, entries)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/function61/gokit/net/http/ezhttp"
+	"github.com/function61/gokit/os/osutil"
+	"github.com/function61/gokit/testing/assert"
+	"github.com/joonas-fi/shopping-list-manager/pkg/resilienthttp"
+)
+
+func TestIsTransientError(t *testing.T) {
+	statusErr := func(statusCode int) error {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(statusCode)
+		}))
+		defer srv.Close()
+
+		_, err := ezhttp.Get(context.Background(), srv.URL)
+		return fmt.Errorf("CreateTask: %w", err)
+	}
+
+	connectionRefused := func() error {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		srv.Close()
+
+		_, err := ezhttp.Get(context.Background(), srv.URL)
+		return fmt.Errorf("CreateTask: %w", err)
+	}
+
+	assert.Equal(t, isTransientError(fmt.Errorf("TasksByProject: %w", &net.DNSError{Err: "no such host", Name: "api.todoist.com"})), true)
+	assert.Equal(t, isTransientError(connectionRefused()), true)
+	assert.Equal(t, isTransientError(fmt.Errorf("CreateTask: %w", context.DeadlineExceeded)), true)
+	assert.Equal(t, isTransientError(statusErr(http.StatusServiceUnavailable)), true)
+	assert.Equal(t, isTransientError(statusErr(http.StatusTooManyRequests)), true)
+	assert.Equal(t, isTransientError(statusErr(http.StatusBadRequest)), false)
+	assert.Equal(t, isTransientError(fmt.Errorf("CreateTask: %w", &resilienthttp.Error{Service: "todoist", Kind: resilienthttp.ErrUnavailable, Err: resilienthttp.ErrCircuitOpen})), true)
+	assert.Equal(t, isTransientError(fmt.Errorf("TasksByProject: %w", context.Canceled)), false)
+
+	// permanent errors must not end up in the outbox, as they would block it forever
+	_, errConfig := osutil.GetenvRequired("TODOIST_PROJECT_ID_THAT_IS_NOT_SET")
+	assert.Equal(t, isTransientError(fmt.Errorf("newTodoistShoppingList: %w", errConfig)), false)
+	assert.Equal(t, isTransientError(json.Unmarshal([]byte("{"), &struct{}{})), false)
+	assert.Equal(t, isTransientError(errors.New("Rename: task not found")), false)
+	_, errID := grocyItemID("abc")
+	assert.Equal(t, isTransientError(errID), false)
+}
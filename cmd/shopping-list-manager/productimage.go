@@ -0,0 +1,124 @@
+package main
+
+// Product images for vision-capable AI models: either from web search results or uploaded photos
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/joonas-fi/shopping-list-manager/pkg/openai"
+	"github.com/joonas-fi/shopping-list-manager/pkg/webpage"
+	"github.com/joonas-fi/shopping-list-manager/pkg/websearch"
+)
+
+const (
+	maxProductImageSize = 4 * 1024 * 1024
+
+	// no use downloading images from every search result if the first ones fail
+	maxProductImageAttempts = 2
+
+	imagePromptAddendum = "\nI've also attached an image of the product. Use it especially if the search results are ambiguous, conflicting or missing.\n"
+)
+
+// returns data URL of the first downloadable product image, or "" if there is none
+func productImageFromSearchResults(ctx context.Context, searchResults []websearch.Result, logger *slog.Logger) string {
+	candidates := []string{}
+	for _, result := range searchResults {
+		// structured product data's image is more likely an actual packshot than a page's thumbnail
+		if result.Product != nil && result.Product.Image != "" {
+			candidates = append(candidates, result.Product.Image)
+		}
+		if result.Image != "" {
+			candidates = append(candidates, result.Image)
+		}
+	}
+
+	for _, candidate := range candidates[:min(len(candidates), maxProductImageAttempts)] {
+		image, err := downloadImageAsDataURL(ctx, candidate)
+		if err != nil {
+			logger.Warn("downloadImageAsDataURL", "url", candidate, "err", err)
+			continue
+		}
+
+		return image
+	}
+
+	return ""
+}
+
+// not all AI providers fetch remote images themselves, so we send them inline
+func downloadImageAsDataURL(ctx context.Context, imageURL string) (string, error) {
+	withErr := func(err error) (string, error) { return "", fmt.Errorf("downloadImageAsDataURL: %w", err) }
+
+	image, err := webpage.Fetch(ctx, imageURL)
+	if err != nil {
+		return withErr(err)
+	}
+	if !image.OK() {
+		return withErr(fmt.Errorf("%s: %s", image.URL, image.Status))
+	}
+
+	return imageAsDataURL(image.Body)
+}
+
+func imageAsDataURL(image []byte) (string, error) {
+	if len(image) > maxProductImageSize {
+		return "", fmt.Errorf("image too large (over %d bytes)", maxProductImageSize)
+	}
+
+	// sniffing instead of trusting the server (or browser) since they're sometimes wrong
+	contentType := http.DetectContentType(image)
+	if !strings.HasPrefix(contentType, "image/") {
+		return "", fmt.Errorf("not an image: %s", contentType)
+	}
+
+	return openai.ImageDataURL(contentType, image), nil
+}
+
+// for store-brand items whose web search results are junk: a photo of the package taken by the user
+func resolveProductDetailsFromPhoto(ctx context.Context, barcode string, photo []byte, logger *slog.Logger) (*productDetails, error) {
+	withErr := func(err error) (*productDetails, error) {
+		return nil, fmt.Errorf("resolveProductDetailsFromPhoto: %w", err)
+	}
+
+	image, err := imageAsDataURL(photo)
+	if err != nil {
+		return withErr(err)
+	}
+
+	conf, err := getAIConfig()
+	if err != nil {
+		return withErr(err)
+	}
+
+	searchResults, err := func() ([]websearch.Result, error) {
+		if isURL(barcode) || len(barcode) < 10 { // not searchable (see `resolveProductDetailsByBarcode()`)
+			return nil, nil
+		}
+
+		searchEngine, err := getSearcher()
+		if err != nil {
+			return nil, err
+		}
+
+		return searchEngine.Search(ctx, barcode)
+	}()
+	if err != nil { // photo alone can be enough
+		logger.Warn("resolveProductDetailsFromPhoto: web search failed", "err", err)
+	}
+
+	link := ""
+	if len(searchResults) > 0 {
+		link = searchResults[0].Link
+	}
+
+	details, err := guessProductDetailsWithAI(ctx, conf.Client(), *conf, searchResults, image, link, logger)
+	if err != nil {
+		return withErr(err)
+	}
+
+	return details, nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/function61/gokit/testing/assert"
+)
+
+func TestImageAsDataURL(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	dataURL, err := imageAsDataURL(png)
+	assert.Ok(t, err)
+	assert.Equal(t, strings.HasPrefix(dataURL, "data:image/png;base64,"), true)
+
+	_, err = imageAsDataURL([]byte("<html>not found</html>"))
+	assert.Equal(t, err.Error(), "not an image: text/html; charset=utf-8")
+}
+
+func TestChatCompletionReqWithImage(t *testing.T) {
+	req := aiConfig{Model: "gemini"}.ChatCompletionReqWithImage("what is this?", "data:image/png;base64,AAAA")
+
+	userMessage := req.Messages[len(req.Messages)-1]
+	assert.Equal(t, len(userMessage.ContentParts), 2)
+	assert.Equal(t, userMessage.ContentParts[0].Text, "what is this?")
+	assert.Equal(t, userMessage.ContentParts[1].ImageURL.URL, "data:image/png;base64,AAAA")
+}
@@ -0,0 +1,188 @@
+package main
+
+// Purchase history, learned from Todoist webhooks when items are checked off the shopping list.
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/function61/gokit/encoding/jsonfile"
+	"github.com/function61/gokit/time/timeutil"
+	"github.com/joonas-fi/shopping-list-manager/pkg/todoist"
+)
+
+const (
+	purchaseDBName = "purchases.json"
+)
+
+type purchaseEvent struct {
+	At     time.Time `json:"at"`
+	TaskID string    `json:"task_id"` // so redelivered webhooks don't count twice
+}
+
+type purchaseHistory struct {
+	OnListTaskID string          `json:"on_list_task_id,omitempty"` // task of the item that's currently on the shopping list
+	Purchases    []purchaseEvent `json:"purchases,omitempty"`
+}
+
+func (p purchaseHistory) LastBought() *time.Time {
+	if len(p.Purchases) == 0 {
+		return nil
+	}
+	return &p.Purchases[len(p.Purchases)-1].At
+}
+
+func (p purchaseHistory) LastBoughtHumanized() string {
+	lastBought := p.LastBought()
+	if lastBought == nil {
+		return getLocale().Message("web.never")
+	}
+
+	return timeutil.HumanizeDuration(time.Since(*lastBought))
+}
+
+// barcode => history
+type purchaseDB map[string]purchaseHistory
+
+func loadPurchases() (purchaseDB, error) {
+	purchases := purchaseDB{}
+	if err := jsonfile.ReadDisallowUnknownFields(purchaseDBName, &purchases); err != nil {
+		if errors.Is(err, fs.ErrNotExist) { // allowed to not exist - nothing bought yet
+			return purchases, nil
+		} else { // some other error
+			return nil, err
+		}
+	}
+	return purchases, nil
+}
+
+var updatePurchasesMu sync.Mutex
+
+func updatePurchases(modify func(purchases purchaseDB) error) error {
+	updatePurchasesMu.Lock()
+	defer updatePurchasesMu.Unlock()
+
+	purchases, err := loadPurchases()
+	if err != nil {
+		return err
+	}
+
+	if err := modify(purchases); err != nil {
+		return err
+	}
+
+	return jsonfile.Write(purchaseDBName, purchases)
+}
+
+// remembers which task is the barcode's item on the shopping list, so we can map the task back to the
+// barcode even if the task's description is edited
+func recordTaskOnList(barcode string, taskID string) error {
+	return updatePurchases(func(purchases purchaseDB) error {
+		history := purchases[barcode]
+		history.OnListTaskID = taskID
+		purchases[barcode] = history
+		return nil
+	})
+}
+
+var barcodeFromDescriptionRe = regexp.MustCompile(`/item/([^)\s]+)\)`)
+
+// maps task back to barcode via the link in the description (see `createDescriptionMarkdown()`), the
+// stored task ID, or name of an unrecognized barcode task
+func barcodeForTask(item todoist.WebhookEventItem, purchases purchaseDB) (string, bool) {
+	if match := barcodeFromDescriptionRe.FindStringSubmatch(item.Description); match != nil {
+		if barcode, err := url.PathUnescape(match[1]); err == nil {
+			return barcode, true
+		}
+	}
+
+	for barcode, history := range purchases {
+		if history.OnListTaskID == item.ID {
+			return barcode, true
+		}
+	}
+
+	if match := identifyMissRe.FindStringSubmatch(item.Content); match != nil {
+		return match[1], true
+	}
+
+	return "", false
+}
+
+// completed task is a purchase. deleted task was taken off the list without buying it.
+func handleTaskWebhookEvent(event todoist.WebhookEvent, now time.Time) error {
+	withErr := func(err error) error { return fmt.Errorf("handleTaskWebhookEvent: %w", err) }
+
+	if projectID, err := getTodoistProjectID(); err != nil {
+		return withErr(err)
+	} else if event.EventData.ProjectID != projectID { // not our shopping list
+		return nil
+	}
+
+	return updatePurchases(func(purchases purchaseDB) error {
+		barcode, found := barcodeForTask(event.EventData, purchases)
+		if !found { // not added by us (or we lost track of it)
+			return nil
+		}
+
+		history := purchases[barcode]
+
+		if history.OnListTaskID == event.EventData.ID {
+			history.OnListTaskID = ""
+		}
+
+		alreadyRecorded := slices.ContainsFunc(history.Purchases, func(p purchaseEvent) bool { return p.TaskID == event.EventData.ID })
+
+		if event.EventName == todoist.WebhookEventItemCompleted && !alreadyRecorded {
+			slog.Info("purchased", "barcode", barcode, "task", event.EventData.Content)
+
+			history.Purchases = append(history.Purchases, purchaseEvent{At: now.UTC(), TaskID: event.EventData.ID})
+		}
+
+		purchases[barcode] = history
+		return nil
+	})
+}
+
+// receives `item:completed` and `item:deleted` webhooks. the secret is the Todoist app's client secret.
+func todoistWebhookHandler(clientSecret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		event, err := todoist.ParseWebhook(r, clientSecret)
+		if err != nil {
+			slog.Warn("todoistWebhookHandler", "err", err)
+
+			if errors.Is(err, todoist.ErrWebhookSignature) {
+				http.Error(w, "invalid signature", http.StatusUnauthorized)
+			} else {
+				http.Error(w, "invalid event", http.StatusBadRequest)
+			}
+			return
+		}
+
+		switch event.EventName {
+		case todoist.WebhookEventItemCompleted, todoist.WebhookEventItemDeleted:
+			if err := handleTaskWebhookEvent(*event, time.Now()); err != nil {
+				slog.Error("todoistWebhookHandler", "err", err)
+				http.Error(w, "failed to record event", http.StatusInternalServerError) // Todoist will retry
+				return
+			}
+		default: // not subscribed to, but ignore gracefully
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// "" = webhooks not in use
+func getTodoistWebhookSecret() string {
+	return os.Getenv("TODOIST_WEBHOOK_SECRET")
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/function61/gokit/testing/assert"
+	"github.com/joonas-fi/shopping-list-manager/pkg/todoist"
+)
+
+func TestBarcodeForTask(t *testing.T) {
+	purchases := purchaseDB{
+		"6408180733659": purchaseHistory{OnListTaskID: "42"},
+	}
+
+	barcodeFor := func(item todoist.WebhookEventItem) string {
+		barcode, found := barcodeForTask(item, purchases)
+		if !found {
+			return "(not found)"
+		}
+		return barcode
+	}
+
+	assert.Equal(t, barcodeFor(todoist.WebhookEventItem{ID: "1", Description: createDescriptionMarkdown("6410405082657", productDetails{Name: "Maito"})}), "6410405082657")
+	assert.Equal(t, barcodeFor(todoist.WebhookEventItem{ID: "1", Description: "Kevytmaito 1 l\n\n[Details](https://example.com/shopping-list-manager/item/6410405082657)"}), "6410405082657")
+	assert.Equal(t, barcodeFor(todoist.WebhookEventItem{ID: "42", Description: "description edited by a human"}), "6408180733659")
+	assert.Equal(t, barcodeFor(todoist.WebhookEventItem{ID: "1", Content: taskNameForUnnamedBarcode("123")}), "123")
+	assert.Equal(t, barcodeFor(todoist.WebhookEventItem{ID: "1", Content: "Bananas", Description: "[Recipe](https://example.com/recipe)"}), "(not found)")
+}
+
+func TestTodoistWebhookHandler(t *testing.T) {
+	chdirTemp(t) // purchases are stored in the working directory
+	t.Setenv("TODOIST_PROJECT_ID", "123")
+
+	handler := todoistWebhookHandler("secret")
+
+	deliver := func(eventName string, taskID string, projectID string, signature string) int {
+		body := `{"event_name": "` + eventName + `", "event_data": {"id": "` + taskID + `", "project_id": "` + projectID + `", "content": "Maito", "description": "[Details](https://example.com/shopping-list-manager/item/6410405082657)"}}`
+
+		if signature == "" {
+			mac := hmac.New(sha256.New, []byte("secret"))
+			_, _ = mac.Write([]byte(body))
+			signature = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/shopping-list-manager/webhook/todoist", strings.NewReader(body))
+		req.Header.Set("X-Todoist-Hmac-SHA256", signature)
+
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+
+	purchaseTaskIDs := func() string {
+		purchases, err := loadPurchases()
+		assert.Ok(t, err)
+
+		taskIDs := []string{}
+		for _, purchase := range purchases["6410405082657"].Purchases {
+			taskIDs = append(taskIDs, purchase.TaskID)
+		}
+		return strings.Join(taskIDs, ",")
+	}
+
+	assert.Ok(t, recordTaskOnList("6410405082657", "1"))
+
+	assert.Equal(t, deliver(todoist.WebhookEventItemDeleted, "1", "123", ""), http.StatusOK)
+	assert.Equal(t, purchaseTaskIDs(), "") // taken off the list without buying it
+
+	purchases, err := loadPurchases()
+	assert.Ok(t, err)
+	assert.Equal(t, purchases["6410405082657"].OnListTaskID, "")
+
+	assert.Equal(t, deliver(todoist.WebhookEventItemCompleted, "2", "123", ""), http.StatusOK)
+	assert.Equal(t, purchaseTaskIDs(), "2")
+
+	assert.Equal(t, deliver(todoist.WebhookEventItemCompleted, "2", "123", ""), http.StatusOK) // redelivery
+	assert.Equal(t, purchaseTaskIDs(), "2")
+
+	assert.Equal(t, deliver(todoist.WebhookEventItemCompleted, "3", "456", ""), http.StatusOK) // other project
+	assert.Equal(t, purchaseTaskIDs(), "2")
+
+	assert.Equal(t, deliver(todoist.WebhookEventItemCompleted, "4", "123", "forged"), http.StatusUnauthorized)
+	assert.Equal(t, purchaseTaskIDs(), "2")
+}
+
+func TestLastBoughtHumanized(t *testing.T) {
+	t.Setenv("LOCALE", "fi")
+	assert.Equal(t, purchaseHistory{}.LastBoughtHumanized(), "ei koskaan")
+	assert.Equal(t, productDetails{}.LastScannedHumanized(), "ei koskaan")
+	assert.Equal(t, productDetails{}.ConfidenceHumanized(), "tuntematon")
+}
+
+// for tests of code that reads and writes its files in the working directory
+func chdirTemp(t *testing.T) {
+	t.Helper()
+
+	previous, err := os.Getwd()
+	assert.Ok(t, err)
+	assert.Ok(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() {
+		_ = os.Chdir(previous)
+	})
+}
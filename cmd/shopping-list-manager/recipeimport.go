@@ -0,0 +1,190 @@
+package main
+
+// Imports ingredients of a recipe (scanned recipe QR code, or URL entered in the web UI) to the shopping list
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/joonas-fi/shopping-list-manager/pkg/openai"
+	"github.com/joonas-fi/shopping-list-manager/pkg/productpage"
+	"github.com/joonas-fi/shopping-list-manager/pkg/recipe"
+)
+
+type recipeImportResult struct {
+	RecipeName string
+	Added      []string
+	Skipped    []string // already on the shopping list
+	Queued     []string // shopping list unreachable. saved to outbox.
+}
+
+func importRecipeFromURL(ctx context.Context, recipeURL string, list ShoppingList, logger *slog.Logger) (*recipeImportResult, error) {
+	withErr := func(err error) (*recipeImportResult, error) { return nil, fmt.Errorf("importRecipeFromURL: %w", err) }
+
+	page, err := fetchPage(ctx, recipeURL)
+	if err != nil {
+		return withErr(err)
+	}
+
+	// short link to a product. the Digital Link page itself might not even exist (we only need its GTIN).
+	if _, isDigitalLink := productpage.ParseGS1DigitalLink(page.URL); isDigitalLink {
+		return withErr(fmt.Errorf("%w (redirects to GS1 Digital Link %s)", recipe.ErrNoRecipe, page.URL))
+	}
+
+	rec, err := recipe.FromPage(page)
+	if err != nil {
+		return withErr(err)
+	}
+
+	ingredients, err := normalizeIngredientsWithAI(ctx, rec.Ingredients)
+	if err != nil { // AI is a nice-to-have here. the recipe's own wording works as a fallback.
+		logger.Warn("normalizeIngredientsWithAI failed; using ingredients as-is", "err", err)
+
+		ingredients = ingredientsAsIs(rec.Ingredients)
+	}
+
+	result := &recipeImportResult{RecipeName: rec.Name}
+
+	description := fmt.Sprintf("[Recipe: %s](%s)", rec.Name, rec.URL)
+
+	for _, ingredient := range ingredients {
+		if err := addProductNameToShoppingList(ctx, "", ingredient, description, list); err != nil {
+			if errors.Is(err, errItemAlreadyOnShoppingList) {
+				result.Skipped = append(result.Skipped, ingredient.Name)
+				continue
+			}
+			if errors.Is(err, errSavedToOutbox) { // gets added once the list is reachable again
+				result.Queued = append(result.Queued, ingredient.Name)
+				continue
+			}
+
+			return withErr(err)
+		}
+
+		result.Added = append(result.Added, ingredient.Name)
+	}
+
+	logger.Info("imported recipe", "name", rec.Name, "added", len(result.Added), "skipped", len(result.Skipped), "queued", len(result.Queued))
+
+	return result, nil
+}
+
+func audioFeedbackForRecipeImport(result *recipeImportResult) string {
+	if len(result.Queued) > 0 {
+		return getLocale().Message("audio.recipe_saved_to_outbox", len(result.Added)+len(result.Queued), result.RecipeName)
+	}
+
+	return getLocale().Message("audio.recipe_imported", len(result.Added), result.RecipeName)
+}
+
+func ingredientsAsIs(ingredients []string) []productDetails {
+	products := []productDetails{}
+	for _, ingredient := range ingredients {
+		products = append(products, productDetails{Name: ingredient})
+	}
+	return products
+}
+
+// "2 dl ruokakermaa" => "Ruokakerma" (Dairy & Eggs). also drops items that are not worth shopping for (like water).
+func normalizeIngredientsWithAI(ctx context.Context, ingredients []string) ([]productDetails, error) {
+	conf, err := getAIConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := conf.WithTimeout(ctx)
+	defer cancel()
+
+	req := conf.ChatCompletionReq(makeIngredientsPrompt(ingredients))
+	req.ResponseFormat = openai.ResponseFormatJSONSchema("shopping_list_items", ingredientsAnswerSchema())
+
+	res, err := conf.Client().ChatCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.Choices) == 0 {
+		return nil, errors.New("UNEXPECTED: 0 choices in response")
+	}
+
+	return parseIngredientsAnswer(res.Choices[0].Message.Content)
+}
+
+func makeIngredientsPrompt(ingredients []string) string {
+	promptTemplate := `I have list of recipe ingredients (one per line). Turn them into shopping list items:
+
+CODEFENCE
+%s
+CODEFENCE
+
+Use the basic form of the grocery item name without amounts (for example "2 dl ruokakermaa" becomes "Ruokakerma"), in the same language as the ingredient.
+Leave out items that are not bought from a store, like water. Merge duplicates.
+
+Product category is one of these rigid options:
+
+- %s
+
+Please respond in JSON.
+`
+
+	return fmt.Sprintf(strings.ReplaceAll(promptTemplate, "CODEFENCE", "```"),
+		strings.Join(ingredients, "\n"),
+		strings.Join(productCategoriesLabelsOnly, "\n- "),
+	)
+}
+
+type ingredientsAnswer struct {
+	Items []struct {
+		Name            string `json:"name"`
+		ProductCategory string `json:"product_category"`
+	} `json:"items"`
+}
+
+func ingredientsAnswerSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"items": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"name":             map[string]any{"type": "string"},
+						"product_category": map[string]any{"type": "string", "enum": productCategoriesLabelsOnly},
+					},
+					"required":             []string{"name", "product_category"},
+					"additionalProperties": false,
+				},
+			},
+		},
+		"required":             []string{"items"},
+		"additionalProperties": false,
+	}
+}
+
+func parseIngredientsAnswer(answerJSON string) ([]productDetails, error) {
+	answer := &ingredientsAnswer{}
+	if err := json.Unmarshal([]byte(stripCodeFence(answerJSON)), answer); err != nil {
+		return nil, fmt.Errorf("parseIngredientsAnswer: %w", err)
+	}
+
+	products := []productDetails{}
+	for _, item := range answer.Items {
+		if name := strings.TrimSpace(item.Name); name != "" {
+			products = append(products, productDetails{
+				Name:            name,
+				ProductCategory: item.ProductCategory, // unknown category is tolerated (item just won't get an emoji)
+			})
+		}
+	}
+
+	if len(products) == 0 {
+		return nil, errors.New("parseIngredientsAnswer: no items")
+	}
+
+	return products, nil
+}
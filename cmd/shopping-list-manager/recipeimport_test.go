@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/function61/gokit/testing/assert"
+	"github.com/samber/lo"
+)
+
+func TestParseIngredientsAnswer(t *testing.T) {
+	products, err := parseIngredientsAnswer(`{"items": [{"name": "Lohifilee", "product_category": "Meat & Seafood"}, {"name": " ", "product_category": "Other"}, {"name": "Ruokakerma", "product_category": "Dairy & Eggs"}]}`)
+	assert.Ok(t, err)
+
+	assert.Equal(t, strings.Join(lo.Map(products, func(p productDetails, _ int) string { return taskNameForProduct(p) }), ", "), "🥩 Lohifilee, 🥚 Ruokakerma")
+
+	_, err = parseIngredientsAnswer(`{"items": []}`)
+	assert.Equal(t, err.Error(), "parseIngredientsAnswer: no items")
+}
+
+func TestAudioFeedbackForRecipeImport(t *testing.T) {
+	t.Setenv("LOCALE", "en")
+
+	assert.Equal(t, audioFeedbackForRecipeImport(&recipeImportResult{RecipeName: "Lohikeitto", Added: []string{"Lohi", "Peruna"}, Skipped: []string{"Kerma"}}), "Added 2 ingredients from recipe Lohikeitto")
+	assert.Equal(t, audioFeedbackForRecipeImport(&recipeImportResult{RecipeName: "Lohikeitto", Added: []string{"Lohi"}, Queued: []string{"Peruna", "Kerma"}}), "Shopping list unreachable. Saved 3 ingredients from recipe Lohikeitto, will sync later")
+}
@@ -0,0 +1,190 @@
+package main
+
+// Optional grouping of the shopping list with Todoist sections (one per product category), instead of
+// emoji-prefixing task names and computing task order (which Todoist forgets once the list is reordered).
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/function61/gokit/net/http/ezhttp"
+	"github.com/joonas-fi/shopping-list-manager/pkg/todoist"
+	"github.com/samber/lo"
+)
+
+var (
+	categorySections   = map[string]string{} // category label => section ID
+	categorySectionsMu sync.Mutex
+)
+
+// "TODOIST_SECTIONS=true". other shopping lists have no sections, so they keep the emoji prefixes.
+func useSections() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("TODOIST_SECTIONS"))
+	return enabled && getShoppingListBackend() == "todoist"
+}
+
+func sectionNameForCategory(category productCategoryItem) string {
+	return category.Emoji + " " + category.Label
+}
+
+// returns ID of the category's section, creating sections if they don't exist.
+// unknown (or empty) category goes to "Other".
+func sectionForCategory(ctx context.Context, categoryLabel string, projectID string, todo *todoist.Client) (string, error) {
+	category, _ := resolveProductCategory(categoryLabel)
+	if category == nil {
+		category = &productCategories[0]
+	}
+
+	categorySectionsMu.Lock()
+	defer categorySectionsMu.Unlock()
+
+	if sectionID, cached := categorySections[category.Label]; cached {
+		return sectionID, nil
+	}
+
+	sections, err := ensureCategorySections(ctx, projectID, todo)
+	if err != nil {
+		return "", err
+	}
+	categorySections = sections
+
+	return sections[category.Label], nil
+}
+
+// runs `op` with ID of the category's section. if Todoist rejects the request (the user might've deleted
+// the section after we cached its ID), sections are looked up (and re-created) again and `op` retried.
+func withCategorySection(ctx context.Context, categoryLabel string, projectID string, todo *todoist.Client, op func(sectionID string) error) error {
+	sectionID, err := sectionForCategory(ctx, categoryLabel, projectID, todo)
+	if err != nil {
+		return err
+	}
+
+	if err := op(sectionID); err == nil || !isClientError(err) {
+		return err
+	}
+
+	forgetCategorySections()
+
+	sectionID, err = sectionForCategory(ctx, categoryLabel, projectID, todo)
+	if err != nil {
+		return err
+	}
+
+	return op(sectionID)
+}
+
+func forgetCategorySections() {
+	categorySectionsMu.Lock()
+	defer categorySectionsMu.Unlock()
+
+	categorySections = map[string]string{}
+}
+
+// 4xx, except the ones that are worth retrying as-is
+func isClientError(err error) bool {
+	var statusErr *ezhttp.ResponseStatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+
+	code := statusErr.StatusCode()
+	return code >= 400 && code <= 499 && code != http.StatusTooManyRequests && code != http.StatusRequestTimeout
+}
+
+// creates missing sections (in the same order as categories). returns category label => section ID.
+func ensureCategorySections(ctx context.Context, projectID string, todo *todoist.Client) (map[string]string, error) {
+	withErr := func(err error) (map[string]string, error) { return nil, fmt.Errorf("ensureCategorySections: %w", err) }
+
+	existing, err := todo.SectionsByProject(ctx, projectID)
+	if err != nil {
+		return withErr(err)
+	}
+
+	existingByName := lo.SliceToMap(existing, func(section todoist.Section) (string, string) { return section.Name, section.ID })
+
+	sections := map[string]string{}
+	for idx, category := range productCategories {
+		name := sectionNameForCategory(category)
+
+		sectionID, found := existingByName[name]
+		if !found {
+			created, err := todo.CreateSection(ctx, todoist.Section{
+				ProjectID: projectID,
+				Name:      name,
+				Order:     idx + 1,
+			})
+			if err != nil {
+				return withErr(err)
+			}
+			sectionID = created.ID
+		}
+
+		sections[category.Label] = sectionID
+	}
+
+	return sections, nil
+}
+
+// "🥕 Carrots" => "Carrots", Produce
+func stripCategoryEmoji(taskName string) (string, *productCategoryItem) {
+	for _, category := range productCategories {
+		if name, hadEmoji := strings.CutPrefix(taskName, category.Emoji+" "); hadEmoji {
+			return name, &category
+		}
+	}
+
+	return taskName, nil
+}
+
+// one-shot migration of emoji-prefixed tasks (from before section mode) to sections
+func migrateTasksToSections(ctx context.Context, todo *todoist.Client, out io.Writer) error {
+	withErr := func(err error) error { return fmt.Errorf("migrateTasksToSections: %w", err) }
+
+	if !useSections() { // otherwise we'd later look for the tasks by their emoji-prefixed names
+		return withErr(fmt.Errorf("enable section mode first with TODOIST_SECTIONS=true"))
+	}
+
+	projectID, err := getTodoistProjectID()
+	if err != nil {
+		return withErr(err)
+	}
+
+	tasks, err := todo.TasksByProject(ctx, projectID, time.Now())
+	if err != nil {
+		return withErr(err)
+	}
+
+	for _, task := range tasks {
+		name, category := stripCategoryEmoji(task.Content)
+		if category == nil { // not categorized by us
+			continue
+		}
+
+		task.Content = name
+		if err := todo.UpdateTask(ctx, task); err != nil {
+			return withErr(err)
+		}
+
+		if err := withCategorySection(ctx, category.Label, projectID, todo, func(sectionID string) error {
+			if task.SectionID == sectionID {
+				return nil
+			}
+
+			return todo.MoveTask(ctx, task.ID, sectionID)
+		}); err != nil {
+			return withErr(err)
+		}
+
+		_, _ = fmt.Fprintf(out, "%s => %s\n", name, sectionNameForCategory(*category))
+	}
+
+	return nil
+}
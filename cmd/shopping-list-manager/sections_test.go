@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/function61/gokit/net/http/ezhttp"
+	"github.com/function61/gokit/testing/assert"
+)
+
+func TestStripCategoryEmoji(t *testing.T) {
+	name, category := stripCategoryEmoji("🥕 Porkkana")
+	assert.Equal(t, name, "Porkkana")
+	assert.Equal(t, category.Label, "Produce (Fruits & Vegetables)")
+
+	name, category = stripCategoryEmoji("🌶️ Chili (?)")
+	assert.Equal(t, name, "Chili (?)")
+	assert.Equal(t, category.Label, "Spices & Seasonings")
+
+	name, category = stripCategoryEmoji("unrecognized barcode[123]")
+	assert.Equal(t, name, "unrecognized barcode[123]")
+	assert.Equal(t, category == nil, true)
+}
+
+func TestTaskNameForProductInSectionMode(t *testing.T) {
+	carrots := productDetails{Name: "Porkkana", ProductCategory: "Produce (Fruits & Vegetables)"}
+
+	assert.Equal(t, taskNameForProduct(carrots), "🥕 Porkkana")
+
+	t.Setenv("TODOIST_SECTIONS", "true")
+	assert.Equal(t, taskNameForProduct(carrots), "Porkkana")
+	assert.Equal(t, sectionNameForCategory(productCategories[1]), "🥕 Produce (Fruits & Vegetables)")
+
+	t.Setenv("SHOPPING_LIST", "caldav") // has no sections
+	assert.Equal(t, taskNameForProduct(carrots), "🥕 Porkkana")
+}
+
+func TestIsClientError(t *testing.T) {
+	statusErr := func(statusCode int) error {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(statusCode)
+		}))
+		defer srv.Close()
+
+		_, err := ezhttp.Get(context.Background(), srv.URL)
+		return fmt.Errorf("MoveTask: %w", err)
+	}
+
+	assert.Equal(t, isClientError(statusErr(http.StatusNotFound)), true) // deleted section
+	assert.Equal(t, isClientError(statusErr(http.StatusBadRequest)), true)
+	assert.Equal(t, isClientError(statusErr(http.StatusTooManyRequests)), false)
+	assert.Equal(t, isClientError(statusErr(http.StatusInternalServerError)), false)
+	assert.Equal(t, isClientError(fmt.Errorf("MoveTask: %w", context.DeadlineExceeded)), false)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+)
+
+// where scanned items end up. the scan pipeline only talks to this, so the list can live in
+// different services.
+type ShoppingList interface {
+	// uncompleted items. `fresh` bypasses the implementation's cache (if it has one).
+	Items(ctx context.Context, fresh bool) ([]ShoppingListItem, error)
+	Add(ctx context.Context, item ShoppingListItem) (*ShoppingListItem, error)
+	// renames the item (found by ID). description, category and labels are updated as well.
+	Rename(ctx context.Context, item ShoppingListItem) error
+	Complete(ctx context.Context, id string) error
+	Remove(ctx context.Context, id string) error
+}
+
+type ShoppingListItem struct {
+	ID          string
+	Name        string
+	Description string   // Markdown
+	Category    string   // product category label. how (or if) it's shown depends on the list.
+	Labels      []string // lists that don't support labels ignore these
+	Order       int      // position hint when adding (0 = list's default)
+	Barcode     string   // if known when adding. lists that have their own product database can link the item to it.
+}
+
+// "SHOPPING_LIST=homeassistant"
+func getShoppingListBackend() string {
+	return cmp.Or(os.Getenv("SHOPPING_LIST"), "todoist")
+}
+
+func getShoppingList() (ShoppingList, error) {
+	switch backend := getShoppingListBackend(); backend {
+	case "todoist":
+		return newTodoistShoppingList()
+	case "homeassistant":
+		return newHomeAssistantShoppingList()
+	case "caldav":
+		return newCalDAVShoppingList()
+	case "grocy":
+		return newGrocyShoppingList()
+	default:
+		return nil, fmt.Errorf("unsupported SHOPPING_LIST: %s", backend)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/function61/gokit/os/osutil"
+	"github.com/joonas-fi/shopping-list-manager/pkg/caldav"
+	"github.com/samber/lo"
+)
+
+// items are VTODO entries in a CalDAV collection (Nextcloud Tasks, Apple Reminders-style lists..).
+// category and labels are carried as the entry's CATEGORIES.
+type calDAVShoppingList struct {
+	client *caldav.Client
+}
+
+var _ ShoppingList = (*calDAVShoppingList)(nil)
+
+func newCalDAVShoppingList() (*calDAVShoppingList, error) {
+	collectionURL, err := osutil.GetenvRequired("CALDAV_URL")
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := caldav.NewClient(collectionURL, os.Getenv("CALDAV_USERNAME"), os.Getenv("CALDAV_PASSWORD"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &calDAVShoppingList{client: client}, nil
+}
+
+func (c *calDAVShoppingList) Items(ctx context.Context, _ bool) ([]ShoppingListItem, error) {
+	todos, err := c.client.Todos(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return lo.Map(todos, func(todo caldav.Todo, _ int) ShoppingListItem {
+		// category is the one that is a product category, rest are labels
+		category, _ := lo.Find(todo.Categories, func(value string) bool {
+			known, _ := resolveProductCategory(value)
+			return known != nil
+		})
+
+		return ShoppingListItem{
+			ID:          todo.Href,
+			Name:        todo.Summary,
+			Description: todo.Description,
+			Category:    category,
+			Labels:      lo.Without(todo.Categories, category),
+		}
+	}), nil
+}
+
+func (c *calDAVShoppingList) Add(ctx context.Context, item ShoppingListItem) (*ShoppingListItem, error) {
+	created, err := c.client.CreateTodo(ctx, caldav.Todo{
+		Summary:     item.Name,
+		Description: item.Description,
+		Categories:  calDAVCategories(item),
+	}, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	item.ID = created.Href
+	return &item, nil
+}
+
+func (c *calDAVShoppingList) Rename(ctx context.Context, item ShoppingListItem) error {
+	todo, err := c.client.Todo(ctx, item.ID)
+	if err != nil {
+		return err
+	}
+
+	todo.Summary = item.Name
+	todo.Description = item.Description
+	todo.Categories = calDAVCategories(item)
+
+	return c.client.UpdateTodo(ctx, *todo, time.Now())
+}
+
+func (c *calDAVShoppingList) Complete(ctx context.Context, id string) error {
+	todo, err := c.client.Todo(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	todo.Status = caldav.StatusCompleted
+
+	return c.client.UpdateTodo(ctx, *todo, time.Now())
+}
+
+func (c *calDAVShoppingList) Remove(ctx context.Context, id string) error {
+	return c.client.DeleteTodo(ctx, id)
+}
+
+func calDAVCategories(item ShoppingListItem) []string {
+	return lo.Uniq(lo.Compact(append([]string{item.Category}, item.Labels...)))
+}
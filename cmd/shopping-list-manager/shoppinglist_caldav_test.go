@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/function61/gokit/testing/assert"
+	"github.com/joonas-fi/shopping-list-manager/pkg/caldav"
+	"github.com/samber/lo"
+)
+
+// fake CalDAV server with one collection. stores calendar objects as-is.
+func calDAVFake(t *testing.T) (*httptest.Server, map[string]string) {
+	objects := map[string]string{} // href => data
+	etags := map[string]int{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, _ := r.BasicAuth(); username != "joonas" || password != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		etag := func(href string) string { return fmt.Sprintf(`"%d"`, etags[href]) }
+
+		switch r.Method {
+		case "REPORT":
+			assert.Equal(t, r.URL.Path, "/dav/shopping/")
+			assert.Equal(t, r.Header.Get("Depth"), "1")
+
+			hrefs := []string{}
+			for href := range objects {
+				hrefs = append(hrefs, href)
+			}
+			sort.Strings(hrefs)
+
+			responses := []string{}
+			for _, href := range hrefs {
+				if strings.Contains(objects[href], "\r\nCOMPLETED:") { // is-not-defined filter
+					continue
+				}
+				responses = append(responses, fmt.Sprintf(`<d:response><d:href>%s</d:href><d:propstat><d:prop><d:getetag>%s</d:getetag><cal:calendar-data>%s</cal:calendar-data></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`,
+					href, html.EscapeString(etag(href)), html.EscapeString(objects[href])))
+			}
+
+			w.WriteHeader(http.StatusMultiStatus)
+			_, _ = fmt.Fprintf(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:" xmlns:cal="urn:ietf:params:xml:ns:caldav">%s</d:multistatus>`, strings.Join(responses, ""))
+		case http.MethodGet:
+			data, found := objects[r.URL.Path]
+			if !found {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("ETag", etag(r.URL.Path))
+			_, _ = w.Write([]byte(data))
+		case http.MethodPut:
+			_, exists := objects[r.URL.Path]
+			if r.Header.Get("If-None-Match") == "*" && exists {
+				http.Error(w, "exists", http.StatusPreconditionFailed)
+				return
+			}
+			if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && ifMatch != etag(r.URL.Path) {
+				http.Error(w, "changed", http.StatusPreconditionFailed)
+				return
+			}
+
+			data, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = string(data)
+			etags[r.URL.Path]++
+			w.WriteHeader(lo.Ternary(exists, http.StatusNoContent, http.StatusCreated))
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "unsupported method", http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, objects
+}
+
+func TestCalDAVShoppingList(t *testing.T) {
+	ctx := context.Background()
+
+	srv, objects := calDAVFake(t)
+
+	client, err := caldav.NewClient(srv.URL+"/dav/shopping", "joonas", "secret")
+	assert.Ok(t, err)
+	list := &calDAVShoppingList{client: client}
+
+	assert.Ok(t, addTaskToShoppingList(ctx, taskSpec{Name: taskNameForUnnamedBarcode("123"), Description: "[Details](http://example.com/item/123)"}, list))
+	assert.Ok(t, addTaskToShoppingList(ctx, taskSpec{Name: "Bananas", Category: "Produce (Fruits & Vegetables)"}, list))
+	assert.Equal(t, errors.Is(addTaskToShoppingList(ctx, taskSpec{Name: "Bananas"}, list), errItemAlreadyOnShoppingList), true)
+
+	misses, err := listMisses(ctx, list)
+	assert.Ok(t, err)
+	assert.Equal(t, strings.Join(misses, ","), "123")
+
+	assert.Ok(t, renameTasksInternal(ctx, []string{taskNameForUnnamedBarcode("123")}, taskSpec{
+		Name:        "Maito",
+		Description: "Kevytmaito, 1 l",
+		Category:    "Dairy & Eggs",
+		Labels:      []string{"ai-guessed"},
+	}, nil, list))
+
+	items, err := list.Items(ctx, false)
+	assert.Ok(t, err)
+	summary := func(items []ShoppingListItem) string {
+		lines := []string{}
+		for _, item := range items {
+			lines = append(lines, fmt.Sprintf("%s [%s] %v %q", item.Name, item.Category, item.Labels, item.Description))
+		}
+		sort.Strings(lines)
+		return strings.Join(lines, "\n")
+	}
+	assert.Equal(t, summary(items), `Bananas [Produce (Fruits & Vegetables)] [] ""
+Maito [Dairy & Eggs] [ai-guessed] "Kevytmaito, 1 l"`)
+
+	bananas := items[0]
+	if bananas.Name != "Bananas" {
+		bananas = items[1]
+	}
+	assert.Ok(t, list.Complete(ctx, bananas.ID))
+	assert.Equal(t, strings.Contains(objects[bananas.ID], "\r\nSTATUS:COMPLETED\r\n"), true)
+
+	items, err = list.Items(ctx, false)
+	assert.Ok(t, err)
+	assert.Equal(t, summary(items), `Maito [Dairy & Eggs] [ai-guessed] "Kevytmaito, 1 l"`)
+
+	assert.Ok(t, list.Remove(ctx, items[0].ID))
+	assert.Equal(t, len(objects), 1) // the completed one
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/joonas-fi/shopping-list-manager/pkg/grocy"
+	"github.com/samber/lo"
+)
+
+// items are items of a Grocy shopping list. the name is the item's note (or its product's name). if
+// Grocy knows the scanned barcode, the item is also linked to the Grocy product. Grocy has no labels for
+// items and categories come from the product (groups), so those are not stored.
+type grocyShoppingList struct {
+	client         *grocy.Client
+	shoppingListID int
+}
+
+var _ ShoppingList = (*grocyShoppingList)(nil)
+
+func newGrocyShoppingList() (*grocyShoppingList, error) {
+	client, err := getGrocy()
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, errGrocyNotConfigured
+	}
+
+	shoppingListID := 1 // Grocy's default list
+	if fromEnv := os.Getenv("GROCY_SHOPPING_LIST_ID"); fromEnv != "" {
+		shoppingListID, err = strconv.Atoi(fromEnv)
+		if err != nil {
+			return nil, fmt.Errorf("GROCY_SHOPPING_LIST_ID: %w", err)
+		}
+	}
+
+	return &grocyShoppingList{client: client, shoppingListID: shoppingListID}, nil
+}
+
+func (g *grocyShoppingList) Items(ctx context.Context, _ bool) ([]ShoppingListItem, error) {
+	items, err := g.client.ShoppingListItems(ctx, g.shoppingListID)
+	if err != nil {
+		return nil, err
+	}
+
+	// items added in Grocy's UI usually have only the product
+	productNames := map[grocy.ID]string{}
+	for _, item := range items {
+		if item.Note != "" || item.ProductID == 0 {
+			continue
+		}
+		if _, fetched := productNames[item.ProductID]; fetched {
+			continue
+		}
+
+		product, err := g.client.ProductByID(ctx, item.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		if product != nil {
+			productNames[item.ProductID] = product.Name
+		}
+	}
+
+	return lo.Map(items, func(item grocy.ShoppingListItem, _ int) ShoppingListItem {
+		return ShoppingListItem{
+			ID:   item.ID.String(),
+			Name: cmp.Or(item.Note, productNames[item.ProductID]),
+		}
+	}), nil
+}
+
+func (g *grocyShoppingList) Add(ctx context.Context, item ShoppingListItem) (*ShoppingListItem, error) {
+	productID := grocy.ID(0)
+	if item.Barcode != "" {
+		product, err := g.client.ProductByBarcode(ctx, item.Barcode)
+		if err != nil {
+			return nil, err
+		}
+		if product != nil {
+			productID = product.ID
+		}
+	}
+
+	id, err := g.client.AddShoppingListItem(ctx, g.shoppingListID, productID, item.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	item.ID = id.String()
+	return &item, nil
+}
+
+func (g *grocyShoppingList) Rename(ctx context.Context, item ShoppingListItem) error {
+	id, err := grocyItemID(item.ID)
+	if err != nil {
+		return err
+	}
+
+	return g.client.UpdateShoppingListItem(ctx, id, map[string]any{"note": item.Name})
+}
+
+func (g *grocyShoppingList) Complete(ctx context.Context, id string) error {
+	itemID, err := grocyItemID(id)
+	if err != nil {
+		return err
+	}
+
+	return g.client.UpdateShoppingListItem(ctx, itemID, map[string]any{"done": 1})
+}
+
+func (g *grocyShoppingList) Remove(ctx context.Context, id string) error {
+	itemID, err := grocyItemID(id)
+	if err != nil {
+		return err
+	}
+
+	return g.client.DeleteShoppingListItem(ctx, itemID)
+}
+
+func grocyItemID(id string) (grocy.ID, error) {
+	parsed, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, fmt.Errorf("grocyItemID: %w", err)
+	}
+	return grocy.ID(parsed), nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	. "github.com/function61/gokit/builtin"
+	"github.com/function61/gokit/os/osutil"
+	"github.com/joonas-fi/shopping-list-manager/pkg/homeassistant"
+	"github.com/samber/lo"
+)
+
+// items are items of a Home Assistant to-do list entity (like `todo.shopping_list`). HA has no
+// categories or labels for items, so category is only shown as an emoji prefix in the name.
+type homeAssistantShoppingList struct {
+	ha       *homeassistant.Client
+	entityID string
+
+	supportsDescription *bool // lazily resolved
+	mu                  sync.Mutex
+}
+
+var _ ShoppingList = (*homeAssistantShoppingList)(nil)
+
+func newHomeAssistantShoppingList() (*homeAssistantShoppingList, error) {
+	baseURL, err := osutil.GetenvRequired("HOME_ASSISTANT_URL")
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := osutil.GetenvRequired("HOME_ASSISTANT_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+
+	entityID, err := osutil.GetenvRequired("HOME_ASSISTANT_TODO_ENTITY")
+	if err != nil {
+		return nil, err
+	}
+
+	return &homeAssistantShoppingList{
+		ha:       homeassistant.NewClient(baseURL, token),
+		entityID: entityID,
+	}, nil
+}
+
+func (h *homeAssistantShoppingList) Items(ctx context.Context, _ bool) ([]ShoppingListItem, error) {
+	items, err := h.ha.TodoItems(ctx, h.entityID, homeassistant.TodoStatusNeedsAction)
+	if err != nil {
+		return nil, err
+	}
+
+	return lo.Map(items, func(item homeassistant.TodoItem, _ int) ShoppingListItem {
+		return ShoppingListItem{
+			ID:          item.UID,
+			Name:        item.Summary,
+			Description: item.Description,
+		}
+	}), nil
+}
+
+func (h *homeAssistantShoppingList) Add(ctx context.Context, item ShoppingListItem) (*ShoppingListItem, error) {
+	withErr := func(err error) (*ShoppingListItem, error) { return nil, fmt.Errorf("homeAssistant.Add: %w", err) }
+
+	supportsDescription, err := h.supportsDescriptions(ctx)
+	if err != nil {
+		return withErr(err)
+	}
+
+	if err := h.ha.AddTodoItem(ctx, h.entityID, item.Name, lo.Ternary(supportsDescription, item.Description, "")); err != nil {
+		return withErr(err)
+	}
+
+	// adding doesn't tell the new item's UID
+	items, err := h.Items(ctx, true)
+	if err != nil {
+		return withErr(err)
+	}
+
+	sameName := lo.Filter(items, func(existing ShoppingListItem, _ int) bool { return existing.Name == item.Name })
+	if len(sameName) == 0 {
+		return withErr(fmt.Errorf("added item not found: %s", item.Name))
+	}
+
+	item.ID = sameName[len(sameName)-1].ID // newest (to-do lists add to the end)
+	return &item, nil
+}
+
+func (h *homeAssistantShoppingList) Rename(ctx context.Context, item ShoppingListItem) error {
+	supportsDescription, err := h.supportsDescriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	return h.ha.UpdateTodoItem(ctx, h.entityID, item.ID, &item.Name, lo.Ternary(supportsDescription, &item.Description, nil), nil)
+}
+
+func (h *homeAssistantShoppingList) Complete(ctx context.Context, id string) error {
+	return h.ha.UpdateTodoItem(ctx, h.entityID, id, nil, nil, Pointer(homeassistant.TodoStatusCompleted))
+}
+
+func (h *homeAssistantShoppingList) Remove(ctx context.Context, id string) error {
+	return h.ha.RemoveTodoItem(ctx, h.entityID, id)
+}
+
+// not all to-do integrations support descriptions, and setting one for those is an error
+func (h *homeAssistantShoppingList) supportsDescriptions(ctx context.Context) (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.supportsDescription == nil {
+		state, err := h.ha.State(ctx, h.entityID)
+		if err != nil {
+			return false, err
+		}
+
+		h.supportsDescription = Pointer(state.SupportedFeatures()&homeassistant.TodoFeatureSetDescriptionOnItem != 0)
+	}
+
+	return *h.supportsDescription, nil
+}
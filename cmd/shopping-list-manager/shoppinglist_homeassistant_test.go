@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/function61/gokit/testing/assert"
+	"github.com/joonas-fi/shopping-list-manager/pkg/homeassistant"
+	"github.com/samber/lo"
+)
+
+// stub of Home Assistant's REST API with one to-do list entity
+func homeAssistantStub(t *testing.T, supportedFeatures int) (*httptest.Server, *[]homeassistant.TodoItem) {
+	items := []homeassistant.TodoItem{}
+	nextUID := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer dummy" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if r.URL.Path == "/api/states/todo.shopping_list" {
+			_, _ = fmt.Fprintf(w, `{"entity_id": "todo.shopping_list", "state": "%d", "attributes": {"supported_features": %d}}`, len(items), supportedFeatures)
+			return
+		}
+
+		data := map[string]any{}
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		assert.Equal(t, data["entity_id"], "todo.shopping_list")
+
+		if _, hasDescription := data["description"]; hasDescription && supportedFeatures&homeassistant.TodoFeatureSetDescriptionOnItem == 0 {
+			http.Error(w, "Entity does not support setting field 'description'", http.StatusBadRequest)
+			return
+		}
+
+		find := func() int {
+			return slices.IndexFunc(items, func(item homeassistant.TodoItem) bool {
+				return item.UID == data["item"] || item.Summary == data["item"]
+			})
+		}
+
+		switch r.URL.Path {
+		case "/api/services/todo/get_items":
+			assert.Equal(t, r.URL.RawQuery, "return_response")
+
+			needsAction := lo.Filter(items, func(item homeassistant.TodoItem, _ int) bool {
+				return item.Status == homeassistant.TodoStatusNeedsAction
+			})
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"changed_states":   []any{},
+				"service_response": map[string]any{"todo.shopping_list": map[string]any{"items": needsAction}},
+			})
+			return
+		case "/api/services/todo/add_item":
+			nextUID++
+			description, _ := data["description"].(string)
+			items = append(items, homeassistant.TodoItem{UID: fmt.Sprintf("uid-%d", nextUID), Summary: data["item"].(string), Description: description, Status: homeassistant.TodoStatusNeedsAction})
+		case "/api/services/todo/update_item":
+			idx := find()
+			if idx == -1 {
+				http.Error(w, "item not found", http.StatusBadRequest)
+				return
+			}
+			if rename, ok := data["rename"].(string); ok {
+				items[idx].Summary = rename
+			}
+			if description, ok := data["description"].(string); ok {
+				items[idx].Description = description
+			}
+			if status, ok := data["status"].(string); ok {
+				items[idx].Status = status
+			}
+		case "/api/services/todo/remove_item":
+			items = slices.DeleteFunc(items, func(item homeassistant.TodoItem) bool { return item.UID == data["item"] })
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		_, _ = w.Write([]byte(`[]`)) // changed states
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &items
+}
+
+func TestHomeAssistantShoppingList(t *testing.T) {
+	ctx := context.Background()
+
+	for _, supportedFeatures := range []int{7, 71} { // without and with descriptions
+		srv, items := homeAssistantStub(t, supportedFeatures)
+
+		list := &homeAssistantShoppingList{ha: homeassistant.NewClient(srv.URL, "dummy"), entityID: "todo.shopping_list"}
+
+		assert.Ok(t, addTaskToShoppingList(ctx, taskSpec{Name: taskNameForUnnamedBarcode("123"), Description: "[Details](http://example.com/item/123)"}, list))
+		assert.Ok(t, addTaskToShoppingList(ctx, taskSpec{Name: "Bananas"}, list))
+		assert.Ok(t, addTaskToShoppingList(ctx, taskSpec{Name: "Bread"}, list))
+		assert.Equal(t, errors.Is(addTaskToShoppingList(ctx, taskSpec{Name: "Bananas"}, list), errItemAlreadyOnShoppingList), true)
+
+		misses, err := listMisses(ctx, list)
+		assert.Ok(t, err)
+		assert.Equal(t, strings.Join(misses, ","), "123")
+
+		assert.Ok(t, renameTasksInternal(ctx, []string{taskNameForUnnamedBarcode("123")}, taskSpec{Name: "Maito", Description: "Kevytmaito 1 l"}, nil, list))
+		assert.Ok(t, list.Complete(ctx, "uid-2"))
+		assert.Ok(t, list.Remove(ctx, "uid-3"))
+
+		assert.Equal(t, fmt.Sprintf("%v", *items), map[int]string{
+			7:  "[{uid-1 Maito  needs_action} {uid-2 Bananas  completed}]",
+			71: "[{uid-1 Maito Kevytmaito 1 l needs_action} {uid-2 Bananas  completed}]",
+		}[supportedFeatures])
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/function61/gokit/testing/assert"
+)
+
+// in-memory fake for testing the scan pipeline without a real shopping list
+type memoryShoppingList struct {
+	items  []ShoppingListItem
+	nextID int
+	mu     sync.Mutex
+}
+
+var _ ShoppingList = (*memoryShoppingList)(nil)
+
+func (m *memoryShoppingList) Items(_ context.Context, _ bool) ([]ShoppingListItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return slices.Clone(m.items), nil
+}
+
+func (m *memoryShoppingList) Add(_ context.Context, item ShoppingListItem) (*ShoppingListItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	item.ID = fmt.Sprintf("%d", m.nextID)
+	m.items = append(m.items, item)
+	return &item, nil
+}
+
+func (m *memoryShoppingList) Rename(_ context.Context, item ShoppingListItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx := slices.IndexFunc(m.items, func(existing ShoppingListItem) bool { return existing.ID == item.ID })
+	if idx == -1 {
+		return errors.New("not found")
+	}
+	m.items[idx] = item
+	return nil
+}
+
+func (m *memoryShoppingList) Complete(ctx context.Context, id string) error {
+	return m.Remove(ctx, id) // completed items are not listed
+}
+
+func (m *memoryShoppingList) Remove(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.items = slices.DeleteFunc(m.items, func(item ShoppingListItem) bool { return item.ID == id })
+	return nil
+}
+
+func (m *memoryShoppingList) String() string {
+	lines := []string{}
+	for _, item := range m.items {
+		lines = append(lines, fmt.Sprintf("%s: %s [%s] %v", item.ID, item.Name, item.Category, item.Labels))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestAddAndRenameTasks(t *testing.T) {
+	ctx := context.Background()
+	list := &memoryShoppingList{}
+
+	assert.Ok(t, addTaskToShoppingList(ctx, taskSpec{Name: taskNameForUnnamedBarcode("123")}, list))
+	assert.Ok(t, addTaskToShoppingList(ctx, taskSpec{Name: "Bananas", Category: "Produce (Fruits & Vegetables)"}, list))
+	assert.Equal(t, errors.Is(addTaskToShoppingList(ctx, taskSpec{Name: "Bananas"}, list), errItemAlreadyOnShoppingList), true)
+
+	misses, err := listMisses(ctx, list)
+	assert.Ok(t, err)
+	assert.Equal(t, strings.Join(misses, ","), "123")
+
+	assert.Ok(t, renameTasksInternal(ctx, []string{taskNameForUnnamedBarcode("123")}, taskSpec{
+		Name:     "Maito",
+		Category: "Dairy & Eggs",
+		Labels:   []string{"needs-review"},
+	}, nil, list))
+
+	list.items[0].Labels = append(list.items[0].Labels, "urgent") // added by a human
+
+	assert.Ok(t, renameTasksInternal(ctx, []string{"Maito"}, taskSpec{
+		Name:     "Kevytmaito",
+		Category: "Dairy & Eggs",
+		Labels:   []string{"manual"},
+	}, []string{"needs-review"}, list))
+
+	assert.Equal(t, list.String(), `1: Kevytmaito [Dairy & Eggs] [urgent manual]
+2: Bananas [Produce (Fruits & Vegetables)] []`)
+}
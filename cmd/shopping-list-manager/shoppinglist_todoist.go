@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/joonas-fi/shopping-list-manager/pkg/todoist"
+	"github.com/samber/lo"
+)
+
+// the list is read from a local mirror (kept in sync with Todoist's sync API) that is refreshed if it's
+// older than this. a scan usually reads the list multiple times, which this makes cheap.
+const shoppingListMirrorMaxAge = 10 * time.Second
+
+// items are tasks in a Todoist project. category is shown as a section (in section mode) or as an emoji
+// prefix in the task name (see `taskNameForProduct()`).
+type todoistShoppingList struct {
+	todo      *todoist.Client
+	projectID string
+}
+
+var _ ShoppingList = (*todoistShoppingList)(nil)
+
+func newTodoistShoppingList() (*todoistShoppingList, error) {
+	todo, err := getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	projectID, err := getTodoistProjectID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &todoistShoppingList{todo: todo, projectID: projectID}, nil
+}
+
+func (t *todoistShoppingList) Items(ctx context.Context, fresh bool) ([]ShoppingListItem, error) {
+	tasks, err := t.todo.MirroredTasksByProject(ctx, t.projectID, lo.Ternary(fresh, 0, shoppingListMirrorMaxAge))
+	if err != nil {
+		return nil, err
+	}
+
+	return lo.Map(tasks, func(task todoist.Task, _ int) ShoppingListItem {
+		return ShoppingListItem{
+			ID:          task.ID,
+			Name:        task.Content,
+			Description: task.Description,
+			Labels:      task.Labels,
+		}
+	}), nil
+}
+
+func (t *todoistShoppingList) Add(ctx context.Context, item ShoppingListItem) (*ShoppingListItem, error) {
+	if err := ensureLabelsExist(ctx, item.Labels, t.todo); err != nil {
+		return nil, err
+	}
+
+	var created *todoist.Task
+	if err := t.withSectionID(ctx, item.Category, func(sectionID string) error {
+		var err error
+		created, err = t.todo.CreateTask(ctx, todoist.Task{
+			Content:     item.Name,
+			Description: item.Description,
+			ProjectID:   t.projectID,
+			SectionID:   sectionID,
+			Order:       item.Order,
+			Labels:      item.Labels,
+		})
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	item.ID = created.ID
+	return &item, nil
+}
+
+func (t *todoistShoppingList) Rename(ctx context.Context, item ShoppingListItem) error {
+	tasks, err := t.todo.MirroredTasksByProject(ctx, t.projectID, shoppingListMirrorMaxAge)
+	if err != nil {
+		return err
+	}
+
+	task, found := lo.Find(tasks, func(task todoist.Task) bool { return task.ID == item.ID })
+	if !found {
+		return fmt.Errorf("Rename: task not found: %s", item.ID)
+	}
+
+	if err := ensureLabelsExist(ctx, item.Labels, t.todo); err != nil {
+		return err
+	}
+
+	updated := task
+	updated.Content = item.Name
+	updated.Description = item.Description
+	updated.Labels = item.Labels
+
+	if updated.Content != task.Content || updated.Description != task.Description || !slices.Equal(updated.Labels, task.Labels) {
+		if err := t.todo.UpdateTask(ctx, updated); err != nil {
+			return err
+		}
+	}
+
+	return t.withSectionID(ctx, item.Category, func(sectionID string) error {
+		if sectionID == "" || task.SectionID == sectionID {
+			return nil
+		}
+
+		return t.todo.MoveTask(ctx, task.ID, sectionID)
+	})
+}
+
+func (t *todoistShoppingList) Complete(ctx context.Context, id string) error {
+	return t.todo.CloseTask(ctx, id)
+}
+
+func (t *todoistShoppingList) Remove(ctx context.Context, id string) error {
+	return t.todo.DeleteTask(ctx, id)
+}
+
+// section ID is "" if not in section mode
+func (t *todoistShoppingList) withSectionID(ctx context.Context, category string, op func(sectionID string) error) error {
+	if !useSections() {
+		return op("")
+	}
+
+	return withCategorySection(ctx, category, t.projectID, t.todo, op)
+}
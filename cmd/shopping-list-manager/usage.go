@@ -0,0 +1,355 @@
+package main
+
+// Accounting of AI and web search calls: per-day counts and estimated cost for each provider, so we
+// know what this costs and can stay within a monthly budget.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/function61/gokit/encoding/jsonfile"
+	"github.com/joonas-fi/shopping-list-manager/pkg/openai"
+	"github.com/joonas-fi/shopping-list-manager/pkg/websearch"
+)
+
+const (
+	usageDBName = "usage.json"
+
+	usageProviderAIPrefix     = "ai:"
+	usageProviderSearchPrefix = "search:"
+)
+
+var (
+	errAIBudgetExceeded     = errors.New("monthly AI budget exceeded")
+	errSearchBudgetExceeded = errors.New("monthly web search budget exceeded")
+)
+
+type usageCounters struct {
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens,omitempty"`
+	CompletionTokens int     `json:"completion_tokens,omitempty"`
+	EstimatedCost    float64 `json:"estimated_cost,omitempty"` // USD
+}
+
+func (u usageCounters) Add(other usageCounters) usageCounters {
+	return usageCounters{
+		Requests:         u.Requests + other.Requests,
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		EstimatedCost:    u.EstimatedCost + other.EstimatedCost,
+	}
+}
+
+// day ("2025-09-30") => provider ("ai:gemini-2.5-flash" | "search:google") => counters
+type usageDB map[string]map[string]usageCounters
+
+// totals of providers whose name starts with prefix, for days starting with period ("2025-09" for a month)
+func (u usageDB) Total(period string, providerPrefix string) usageCounters {
+	total := usageCounters{}
+	for day, providers := range u {
+		if !strings.HasPrefix(day, period) {
+			continue
+		}
+
+		for provider, counters := range providers {
+			if strings.HasPrefix(provider, providerPrefix) {
+				total = total.Add(counters)
+			}
+		}
+	}
+	return total
+}
+
+// all-time totals by provider
+func (u usageDB) TotalsByProvider() map[string]usageCounters {
+	totals := map[string]usageCounters{}
+	for _, providers := range u {
+		for provider, counters := range providers {
+			totals[provider] = totals[provider].Add(counters)
+		}
+	}
+	return totals
+}
+
+var (
+	usageDBMu sync.Mutex
+	// budgets are checked on every AI / search request, so this saves reading the file every time. kept
+	// up-to-date by `recordUsage()`. nil = not loaded yet.
+	usageCache usageDB
+)
+
+func loadUsage() (usageDB, error) {
+	usage := usageDB{}
+	if err := jsonfile.ReadDisallowUnknownFields(usageDBName, &usage); err != nil {
+		if errors.Is(err, fs.ErrNotExist) { // allowed to not exist - nothing used yet
+			return usage, nil
+		} else { // some other error
+			return nil, err
+		}
+	}
+	return usage, nil
+}
+
+func recordUsage(provider string, counters usageCounters) error {
+	usageDBMu.Lock()
+	defer usageDBMu.Unlock()
+
+	usage, err := loadUsage()
+	if err != nil {
+		return err
+	}
+
+	today := usageDay(time.Now())
+	if _, found := usage[today]; !found {
+		usage[today] = map[string]usageCounters{}
+	}
+	usage[today][provider] = usage[today][provider].Add(counters)
+
+	if err := jsonfile.Write(usageDBName, usage); err != nil {
+		return err
+	}
+
+	usageCache = usage
+
+	return nil
+}
+
+// like `usageDB.Total()` but from the cache
+func usageTotal(period string, providerPrefix string) (usageCounters, error) {
+	usageDBMu.Lock()
+	defer usageDBMu.Unlock()
+
+	if usageCache == nil {
+		usage, err := loadUsage()
+		if err != nil {
+			return usageCounters{}, err
+		}
+		usageCache = usage
+	}
+
+	return usageCache.Total(period, providerPrefix), nil
+}
+
+func usageDay(ts time.Time) string {
+	return ts.UTC().Format(time.DateOnly)
+}
+
+func usageMonth(ts time.Time) string {
+	return ts.UTC().Format("2006-01")
+}
+
+// USD per million tokens
+type aiModelPrice struct {
+	Input  float64
+	Output float64
+}
+
+func (p aiModelPrice) Cost(usage openai.Usage) float64 {
+	return (float64(usage.PromptTokens)*p.Input + float64(usage.CompletionTokens)*p.Output) / 1_000_000
+}
+
+// list prices at the time of writing. local models are free (if you don't count electricity).
+var aiModelPrices = map[string]aiModelPrice{
+	"gemini-2.5-flash":      {0.30, 2.50},
+	"gemini-2.5-flash-lite": {0.10, 0.40},
+	"gemini-2.5-pro":        {1.25, 10.00},
+	openai.ModelGPT4o:       {2.50, 10.00},
+	"gpt-4o-mini":           {0.15, 0.60},
+}
+
+// USD per 1 000 queries
+var searchProviderPrices = map[string]float64{
+	"google":  5,
+	"brave":   5,
+	"bing":    15,
+	"searxng": 0,
+}
+
+// "AI_PRICE_PER_MILLION_TOKENS=0.30,2.50" (input,output) overrides the built-in price list
+func aiModelPriceFor(model string) (aiModelPrice, error) {
+	override := os.Getenv("AI_PRICE_PER_MILLION_TOKENS")
+	if override == "" {
+		return aiModelPrices[model], nil // unknown models are assumed free (see `isPricedModel()`)
+	}
+
+	input, output, _ := strings.Cut(override, ",")
+	inputPrice, err := strconv.ParseFloat(strings.TrimSpace(input), 64)
+	if err != nil {
+		return aiModelPrice{}, fmt.Errorf("AI_PRICE_PER_MILLION_TOKENS: %w", err)
+	}
+	outputPrice, err := strconv.ParseFloat(strings.TrimSpace(output), 64)
+	if err != nil {
+		return aiModelPrice{}, fmt.Errorf("AI_PRICE_PER_MILLION_TOKENS: %w", err)
+	}
+
+	return aiModelPrice{Input: inputPrice, Output: outputPrice}, nil
+}
+
+func isPricedModel(model string) bool {
+	_, builtIn := aiModelPrices[model]
+	return builtIn || os.Getenv("AI_PRICE_PER_MILLION_TOKENS") != ""
+}
+
+// models we've warned about (so the log isn't flooded)
+var unpricedModelsWarned sync.Map
+
+// "AI_MONTHLY_BUDGET=5" (USD). once exceeded, we switch to AI_BUDGET_FALLBACK_MODEL (if set) or stop using AI.
+func applyAIBudget(conf *aiConfig, spentThisMonth float64) error {
+	budgetStr := os.Getenv("AI_MONTHLY_BUDGET")
+	if budgetStr == "" {
+		return nil
+	}
+
+	budget, err := strconv.ParseFloat(budgetStr, 64)
+	if err != nil {
+		return fmt.Errorf("AI_MONTHLY_BUDGET: %w", err)
+	}
+
+	if _, alreadyWarned := unpricedModelsWarned.LoadOrStore(conf.Model, true); !alreadyWarned && !isPricedModel(conf.Model) {
+		slog.Warn("AI_MONTHLY_BUDGET: model has no known price so its cost is counted as zero. set AI_PRICE_PER_MILLION_TOKENS.", "model", conf.Model)
+	}
+
+	if spentThisMonth < budget {
+		return nil
+	}
+
+	fallbackModel := os.Getenv("AI_BUDGET_FALLBACK_MODEL")
+	if fallbackModel == "" || fallbackModel == conf.Model {
+		return fmt.Errorf("%w (spent %.2f USD of %.2f USD)", errAIBudgetExceeded, spentThisMonth, budget)
+	}
+
+	price, err := aiModelPriceFor(fallbackModel)
+	if err != nil {
+		return err
+	}
+
+	conf.Model = fallbackModel
+	conf.Price = price
+	conf.Vision = false // images are expensive in tokens and the cheaper model might not support them
+
+	return nil
+}
+
+// records usage of the wrapped AI client
+type meteredChatCompleter struct {
+	inner chatCompleter
+	model string
+	price aiModelPrice
+}
+
+func (m meteredChatCompleter) ChatCompletion(ctx context.Context, req openai.ChatCompletionReq) (*openai.ChatCompletionRes, error) {
+	res, err := m.inner.ChatCompletion(ctx, req)
+
+	counters := usageCounters{Requests: 1} // failed requests can cost too, but we don't know how much
+	if err == nil && res.Usage != nil {
+		counters.PromptTokens = res.Usage.PromptTokens
+		counters.CompletionTokens = res.Usage.CompletionTokens
+		counters.EstimatedCost = m.price.Cost(*res.Usage)
+	}
+
+	if errRecord := recordUsage(usageProviderAIPrefix+m.model, counters); errRecord != nil {
+		// not critical for the caller
+		slog.Error("recordUsage", "err", errRecord)
+	}
+
+	return res, err
+}
+
+// "WEB_SEARCH_MONTHLY_BUDGET=5" (USD). once exceeded, paid search providers are not used (free ones,
+// like SearXNG, still are).
+func checkSearchBudget(provider string, spentThisMonth float64) error {
+	budgetStr := os.Getenv("WEB_SEARCH_MONTHLY_BUDGET")
+	if budgetStr == "" || searchProviderPrices[provider] == 0 {
+		return nil
+	}
+
+	budget, err := strconv.ParseFloat(budgetStr, 64)
+	if err != nil {
+		return fmt.Errorf("WEB_SEARCH_MONTHLY_BUDGET: %w", err)
+	}
+
+	if spentThisMonth >= budget {
+		return fmt.Errorf("%w (spent %.2f USD of %.2f USD)", errSearchBudgetExceeded, spentThisMonth, budget)
+	}
+
+	return nil
+}
+
+// records usage of the wrapped search provider. also enforces the search budget.
+type meteredSearcher struct {
+	inner    websearch.Searcher
+	provider string
+}
+
+func (m meteredSearcher) Search(ctx context.Context, query string) ([]websearch.Result, error) {
+	spent, err := usageTotal(usageMonth(time.Now()), usageProviderSearchPrefix)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSearchBudget(m.provider, spent.EstimatedCost); err != nil {
+		return nil, err // `websearch.Fallback()` moves on to next provider
+	}
+
+	results, err := m.inner.Search(ctx, query)
+
+	if errRecord := recordUsage(usageProviderSearchPrefix+m.provider, usageCounters{
+		Requests:      1,
+		EstimatedCost: searchProviderPrices[m.provider] / 1000,
+	}); errRecord != nil {
+		slog.Error("recordUsage", "err", errRecord)
+	}
+
+	return results, err
+}
+
+// Prometheus text exposition format
+func writeUsageMetrics(w io.Writer, usage usageDB, now time.Time) error {
+	totals := usage.TotalsByProvider()
+	providers := slices.Sorted(maps.Keys(totals))
+
+	lines := []string{
+		"# HELP shopping_list_manager_provider_requests_total Requests made to AI and web search providers.",
+		"# TYPE shopping_list_manager_provider_requests_total counter",
+	}
+	for _, provider := range providers {
+		lines = append(lines, fmt.Sprintf(`shopping_list_manager_provider_requests_total{provider=%q} %d`, provider, totals[provider].Requests))
+	}
+
+	lines = append(lines,
+		"# HELP shopping_list_manager_provider_tokens_total Tokens used with AI providers.",
+		"# TYPE shopping_list_manager_provider_tokens_total counter")
+	for _, provider := range providers {
+		if !strings.HasPrefix(provider, usageProviderAIPrefix) {
+			continue
+		}
+		lines = append(lines,
+			fmt.Sprintf(`shopping_list_manager_provider_tokens_total{provider=%q,type="prompt"} %d`, provider, totals[provider].PromptTokens),
+			fmt.Sprintf(`shopping_list_manager_provider_tokens_total{provider=%q,type="completion"} %d`, provider, totals[provider].CompletionTokens))
+	}
+
+	lines = append(lines,
+		"# HELP shopping_list_manager_provider_estimated_cost_usd_total Estimated cost of AI and web search providers.",
+		"# TYPE shopping_list_manager_provider_estimated_cost_usd_total counter")
+	for _, provider := range providers {
+		lines = append(lines, fmt.Sprintf(`shopping_list_manager_provider_estimated_cost_usd_total{provider=%q} %s`, provider, strconv.FormatFloat(totals[provider].EstimatedCost, 'f', -1, 64)))
+	}
+
+	lines = append(lines,
+		"# HELP shopping_list_manager_ai_estimated_cost_this_month_usd Estimated cost of AI this month (compare to AI_MONTHLY_BUDGET).",
+		"# TYPE shopping_list_manager_ai_estimated_cost_this_month_usd gauge",
+		fmt.Sprintf("shopping_list_manager_ai_estimated_cost_this_month_usd %s", strconv.FormatFloat(usage.Total(usageMonth(now), usageProviderAIPrefix).EstimatedCost, 'f', -1, 64)))
+
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/function61/gokit/testing/assert"
+	"github.com/joonas-fi/shopping-list-manager/pkg/openai"
+)
+
+func TestAIModelPriceCost(t *testing.T) {
+	cost := aiModelPrices["gemini-2.5-flash"].Cost(openai.Usage{PromptTokens: 1_000_000, CompletionTokens: 100_000})
+	assert.Equal(t, cost, 0.55)
+}
+
+func TestApplyAIBudget(t *testing.T) {
+	conf := &aiConfig{Model: "gemini-2.5-flash", Vision: true}
+
+	assert.Ok(t, applyAIBudget(conf, 100)) // no budget configured
+
+	t.Setenv("AI_MONTHLY_BUDGET", "5")
+	assert.Ok(t, applyAIBudget(conf, 4.99))
+	assert.Equal(t, conf.Model, "gemini-2.5-flash")
+
+	assert.Equal(t, applyAIBudget(conf, 5).Error(), "monthly AI budget exceeded (spent 5.00 USD of 5.00 USD)")
+
+	t.Setenv("AI_BUDGET_FALLBACK_MODEL", "gemini-2.5-flash-lite")
+	assert.Ok(t, applyAIBudget(conf, 5))
+	assert.Equal(t, conf.Model, "gemini-2.5-flash-lite")
+	assert.Equal(t, conf.Price.Input, 0.10)
+	assert.Equal(t, conf.Vision, false)
+}
+
+func TestCheckSearchBudget(t *testing.T) {
+	assert.Ok(t, checkSearchBudget("google", 100)) // no budget configured
+
+	t.Setenv("WEB_SEARCH_MONTHLY_BUDGET", "2")
+	assert.Ok(t, checkSearchBudget("google", 1.99))
+	assert.Equal(t, checkSearchBudget("google", 2).Error(), "monthly web search budget exceeded (spent 2.00 USD of 2.00 USD)")
+	assert.Ok(t, checkSearchBudget("searxng", 2)) // free
+}
+
+func TestIsPricedModel(t *testing.T) {
+	assert.Equal(t, isPricedModel("gemini-2.5-flash"), true)
+	assert.Equal(t, isPricedModel("my-finetune"), false)
+
+	t.Setenv("AI_PRICE_PER_MILLION_TOKENS", "0.1,0.2")
+	assert.Equal(t, isPricedModel("my-finetune"), true)
+}
+
+func TestWriteUsageMetrics(t *testing.T) {
+	usage := usageDB{
+		"2025-08-31": {
+			"ai:gemini-2.5-flash": {Requests: 1, PromptTokens: 1000, CompletionTokens: 100, EstimatedCost: 0.5},
+		},
+		"2025-09-01": {
+			"ai:gemini-2.5-flash": {Requests: 2, PromptTokens: 2000, CompletionTokens: 200, EstimatedCost: 0.25},
+			"search:google":       {Requests: 3, EstimatedCost: 0.015},
+		},
+	}
+
+	metrics := &strings.Builder{}
+	assert.Ok(t, writeUsageMetrics(metrics, usage, time.Date(2025, 9, 15, 12, 0, 0, 0, time.UTC)))
+
+	assert.EqualString(t, metrics.String(), `# HELP shopping_list_manager_provider_requests_total Requests made to AI and web search providers.
+# TYPE shopping_list_manager_provider_requests_total counter
+shopping_list_manager_provider_requests_total{provider="ai:gemini-2.5-flash"} 3
+shopping_list_manager_provider_requests_total{provider="search:google"} 3
+# HELP shopping_list_manager_provider_tokens_total Tokens used with AI providers.
+# TYPE shopping_list_manager_provider_tokens_total counter
+shopping_list_manager_provider_tokens_total{provider="ai:gemini-2.5-flash",type="prompt"} 3000
+shopping_list_manager_provider_tokens_total{provider="ai:gemini-2.5-flash",type="completion"} 300
+# HELP shopping_list_manager_provider_estimated_cost_usd_total Estimated cost of AI and web search providers.
+# TYPE shopping_list_manager_provider_estimated_cost_usd_total counter
+shopping_list_manager_provider_estimated_cost_usd_total{provider="ai:gemini-2.5-flash"} 0.75
+shopping_list_manager_provider_estimated_cost_usd_total{provider="search:google"} 0.015
+# HELP shopping_list_manager_ai_estimated_cost_this_month_usd Estimated cost of AI this month (compare to AI_MONTHLY_BUDGET).
+# TYPE shopping_list_manager_ai_estimated_cost_this_month_usd gauge
+shopping_list_manager_ai_estimated_cost_this_month_usd 0.25
+`)
+}
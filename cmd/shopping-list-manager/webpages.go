@@ -0,0 +1,66 @@
+package main
+
+// a scanned URL is first tried as a recipe and then as a product page (maybe by the background
+// resolver), so recently fetched pages are remembered to fetch each page only once
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/joonas-fi/shopping-list-manager/pkg/webpage"
+)
+
+const (
+	recentPageMaxAge = 2 * time.Minute
+	recentPagesMax   = 8 // pages can be big
+)
+
+type recentPage struct {
+	page    *webpage.Page
+	fetched time.Time
+}
+
+var (
+	recentPages   = map[string]recentPage{} // URL (as scanned, not after redirects) => page
+	recentPagesMu sync.Mutex
+)
+
+func fetchPage(ctx context.Context, link string) (*webpage.Page, error) {
+	now := time.Now()
+
+	recentPagesMu.Lock()
+	for key, recent := range recentPages {
+		if now.Sub(recent.fetched) > recentPageMaxAge {
+			delete(recentPages, key)
+		}
+	}
+	recent, found := recentPages[link]
+	recentPagesMu.Unlock()
+
+	if found {
+		return recent.page, nil
+	}
+
+	page, err := webpage.Fetch(ctx, link)
+	if err != nil {
+		return nil, err // errors are not remembered. trying again might help.
+	}
+
+	recentPagesMu.Lock()
+	defer recentPagesMu.Unlock()
+
+	if len(recentPages) >= recentPagesMax { // make room by forgetting the oldest
+		oldest := ""
+		for key, recent := range recentPages {
+			if oldest == "" || recent.fetched.Before(recentPages[oldest].fetched) {
+				oldest = key
+			}
+		}
+		delete(recentPages, oldest)
+	}
+
+	recentPages[link] = recentPage{page: page, fetched: now}
+
+	return page, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/function61/gokit/testing/assert"
+	"github.com/joonas-fi/shopping-list-manager/pkg/productpage"
+	"github.com/joonas-fi/shopping-list-manager/pkg/recipe"
+)
+
+func TestScannedURLIsFetchedOnlyOnce(t *testing.T) {
+	ctx := context.Background()
+
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><head><meta property="og:type" content="product" /><meta property="og:title" content="Kaurasämpylä 480 g" /></head></html>`))
+	}))
+	defer srv.Close()
+
+	// first tried as a recipe..
+	page, err := fetchPage(ctx, srv.URL+"/p/123")
+	assert.Ok(t, err)
+	_, err = recipe.FromPage(page)
+	assert.Equal(t, errors.Is(err, recipe.ErrNoRecipe), true)
+
+	// .. then as a product page
+	page, err = fetchPage(ctx, srv.URL+"/p/123")
+	assert.Ok(t, err)
+	product, err := productpage.FromPage(page)
+	assert.Ok(t, err)
+	assert.Equal(t, product.Name, "Kaurasämpylä 480 g")
+
+	assert.Equal(t, requests, 1)
+}
@@ -8,14 +8,16 @@ import (
 	"embed"
 	"fmt"
 	"html/template"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/function61/gokit/net/http/httputils"
-	"github.com/joonas-fi/shopping-list-manager/pkg/todoist"
 	"github.com/samber/lo"
 )
 
@@ -26,8 +28,12 @@ const (
 	appHomeRoute = "/shopping-list-manager/"
 )
 
-func webUI(ctx context.Context, todo *todoist.Client, logger *slog.Logger) error {
-	templates, err := template.ParseFS(templateFiles, "*.html")
+func webUI(ctx context.Context, list ShoppingList, resolver *backgroundResolver, logger *slog.Logger) error {
+	loc := getLocale()
+
+	templates, err := template.New("").Funcs(template.FuncMap{
+		"t": loc.Message,
+	}).ParseFS(templateFiles, "*.html")
 	if err != nil {
 		return err
 	}
@@ -43,8 +49,10 @@ func webUI(ctx context.Context, todo *todoist.Client, logger *slog.Logger) error
 
 		if beep != "" {
 			output := func() string {
-				if _, err := handleBeep(r.Context(), beep, logger, todo); err != nil {
+				if _, resolvingInBackground, err := handleBeep(r.Context(), beep, logger, list, resolver); err != nil {
 					return err.Error()
+				} else if resolvingInBackground {
+					return getLocale().Message("web.added_looking_up")
 				} else {
 					return "ok"
 				}
@@ -59,16 +67,22 @@ func webUI(ctx context.Context, todo *todoist.Client, logger *slog.Logger) error
 		if err != nil {
 			return err
 		}
+		purchases, err := loadPurchases()
+		if err != nil {
+			return err
+		}
 		type productDetailsWrapped struct {
 			productDetails
-			Barcode string
-			ViewURL string
+			Barcode   string
+			ViewURL   string
+			Purchases purchaseHistory
 		}
 		db_ := lo.MapToSlice(*db, func(key string, value productDetails) productDetailsWrapped {
 			return productDetailsWrapped{
 				productDetails: value,
 				Barcode:        key,
 				ViewURL:        "item/" + url.PathEscape(key),
+				Purchases:      purchases[key],
 			}
 		})
 
@@ -88,7 +102,13 @@ func webUI(ctx context.Context, todo *todoist.Client, logger *slog.Logger) error
 		}
 
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		return templates.ExecuteTemplate(w, "index.html", db_)
+		return templates.ExecuteTemplate(w, "index.html", struct {
+			Items            []productDetailsWrapped
+			NeedsReviewCount int
+		}{
+			Items:            db_,
+			NeedsReviewCount: lo.CountBy(lo.Values(*db), func(item productDetails) bool { return item.NeedsReview }),
+		})
 	}))
 
 	routes.HandleFunc("GET "+appHomeRoute+"item/{barcode}", httputils.WrapWithErrorHandling(func(w http.ResponseWriter, r *http.Request) error {
@@ -106,18 +126,26 @@ func webUI(ctx context.Context, todo *todoist.Client, logger *slog.Logger) error
 		if !found {
 			item = newProductDetails(taskNameForUnnamedBarcode(barcode), "")
 		}
+		purchases, err := loadPurchases()
+		if err != nil {
+			return err
+		}
 		type itemWrapped struct {
 			productDetails
 			Barcode           string // since this is found from DB key only (not present in the actual item)
+			PhotoURL          string
 			Found             bool
 			ProductCategories []productCategoryItem
+			Purchases         purchaseHistory
 		}
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		return templates.ExecuteTemplate(w, "item.html", itemWrapped{
 			productDetails:    item,
 			Found:             found,
 			Barcode:           barcode,
+			PhotoURL:          "./" + url.PathEscape(barcode) + "/photo", // keys can be URLs. "./" so that "https:" isn't taken as a scheme.
 			ProductCategories: productCategories,
+			Purchases:         purchases[barcode],
 		})
 	}))
 
@@ -131,31 +159,203 @@ func webUI(ctx context.Context, todo *todoist.Client, logger *slog.Logger) error
 			return err
 		}
 
-		db, err := loadDB()
+		if err := updateProductDetails(r.Context(), barcode, func(item productDetails, found bool) (productDetails, error) {
+			if !found {
+				item = newProductDetails(taskNameForUnnamedBarcode(barcode), "")
+			}
+
+			item.Name = r.FormValue("name")
+			item.DisplayName = r.FormValue("display_name")
+			item.Size = r.FormValue("size")
+			item.Link = r.FormValue("link")
+			item.ProductType = r.FormValue("product_type")
+			item.ProductCategory = r.FormValue("product_category")
+			item.Notes = r.FormValue("notes")
+			item.NeedsReview = false // human has now had a look at it
+			item.Source = productSourceManual
+			return item, nil
+		}, list); err != nil {
+			return err
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, err = fmt.Fprint(w, loc.Message("web.updated", r.FormValue("name")))
+		return err
+	}))
+
+	routes.HandleFunc("POST "+appHomeRoute+"item/{barcode}/photo", httputils.WrapWithErrorHandling(func(w http.ResponseWriter, r *http.Request) error {
+		barcode, err := url.PathUnescape(r.PathValue("barcode"))
 		if err != nil {
 			return err
 		}
 
-		item, found := (*db)[barcode]
-		if !found {
-			item = newProductDetails(taskNameForUnnamedBarcode(barcode), "")
+		if err := r.ParseMultipartForm(maxProductImageSize); err != nil {
+			return err
 		}
 
-		item.Name = r.FormValue("name")
-		item.Link = r.FormValue("link")
-		item.ProductType = r.FormValue("product_type")
-		item.ProductCategory = r.FormValue("product_category")
-		item.Notes = r.FormValue("notes")
+		photoFile, _, err := r.FormFile("photo")
+		if err != nil {
+			return err
+		}
+		defer photoFile.Close()
+
+		photo, err := io.ReadAll(io.LimitReader(photoFile, maxProductImageSize+1))
+		if err != nil {
+			return err
+		}
 
-		if err := recordMissAndStoreToLocalDB(r.Context(), barcode, item, todo); err != nil {
+		item, err := resolveProductDetailsFromPhoto(r.Context(), barcode, photo, logger)
+		if err != nil {
+			return err
+		}
+
+		if err := updateProductDetails(r.Context(), barcode, func(previous productDetails, found bool) (productDetails, error) {
+			if found { // keep scan history
+				item.FirstScanned = previous.FirstScanned
+				item.LastScanned = previous.LastScanned
+			}
+			return *item, nil
+		}, list); err != nil {
 			return err
 		}
 
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		_, err = fmt.Fprintf(w, "updated: %s", item.Name)
+		_, err = fmt.Fprint(w, loc.Message("web.updated", item.Name))
 		return err
 	}))
 
+	routes.HandleFunc("POST "+appHomeRoute+"recipe", httputils.WrapWithErrorHandling(func(w http.ResponseWriter, r *http.Request) error {
+		result, err := importRecipeFromURL(r.Context(), r.FormValue("url"), list, logger)
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, err = fmt.Fprint(w, getLocale().Message("web.recipe_imported",
+			result.RecipeName,
+			strings.Join(result.Added, "\n- "),
+			strings.Join(result.Skipped, "\n- "),
+			strings.Join(result.Queued, "\n- ")))
+		return err
+	}))
+
+	routes.HandleFunc("GET "+appHomeRoute+"review", httputils.WrapWithErrorHandling(func(w http.ResponseWriter, r *http.Request) error {
+		db, err := loadDB()
+		if err != nil {
+			return err
+		}
+
+		type reviewItem struct {
+			productDetails
+			Barcode   string
+			ViewURL   string
+			ReviewURL string
+		}
+		needsReview := lo.FilterMap(lo.Keys(*db), func(barcode string, _ int) (reviewItem, bool) {
+			item := (*db)[barcode]
+			return reviewItem{
+				productDetails: item,
+				Barcode:        barcode,
+				ViewURL:        "item/" + url.PathEscape(barcode),
+				ReviewURL:      "review/" + url.PathEscape(barcode), // keys can be URLs
+			}, item.NeedsReview
+		})
+
+		// least confident first
+		sort.Slice(needsReview, func(i, j int) bool { return needsReview[i].Confidence < needsReview[j].Confidence })
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		return templates.ExecuteTemplate(w, "review.html", needsReview)
+	}))
+
+	routes.HandleFunc("POST "+appHomeRoute+"review/{barcode}", httputils.WrapWithErrorHandling(func(w http.ResponseWriter, r *http.Request) error {
+		barcode, err := url.PathUnescape(r.PathValue("barcode"))
+		if err != nil {
+			return err
+		}
+
+		switch action := r.FormValue("action"); action {
+		case "approve":
+			if err := updateProductDetails(r.Context(), barcode, func(item productDetails, found bool) (productDetails, error) {
+				if !found {
+					return item, fmt.Errorf("barcode not found: %s", barcode)
+				}
+
+				item.NeedsReview = false
+				return item, nil
+			}, list); err != nil {
+				return err
+			}
+		case "reject":
+			if err := rejectProductDetails(r.Context(), barcode, list); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported action: %s", action)
+		}
+
+		http.Redirect(w, r, appHomeRoute+"review", http.StatusFound)
+		return nil
+	}))
+
+	routes.HandleFunc("GET "+appHomeRoute+"usage", httputils.WrapWithErrorHandling(func(w http.ResponseWriter, r *http.Request) error {
+		usage, err := loadUsage()
+		if err != nil {
+			return err
+		}
+
+		type usageRow struct {
+			usageCounters
+			Day      string
+			Provider string
+		}
+		rows := []usageRow{}
+		for _, day := range lo.Keys(usage) {
+			for provider, counters := range usage[day] {
+				rows = append(rows, usageRow{usageCounters: counters, Day: day, Provider: provider})
+			}
+		}
+
+		// newest first
+		sort.Slice(rows, func(i, j int) bool {
+			if rows[i].Day != rows[j].Day {
+				return rows[i].Day > rows[j].Day
+			}
+			return rows[i].Provider < rows[j].Provider
+		})
+
+		thisMonth := usageMonth(time.Now())
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		return templates.ExecuteTemplate(w, "usage.html", struct {
+			Month       string
+			AIThisMonth usageCounters
+			SearchMonth usageCounters
+			AIBudget    string
+			Rows        []usageRow
+		}{
+			Month:       thisMonth,
+			AIThisMonth: usage.Total(thisMonth, usageProviderAIPrefix),
+			SearchMonth: usage.Total(thisMonth, usageProviderSearchPrefix),
+			AIBudget:    os.Getenv("AI_MONTHLY_BUDGET"),
+			Rows:        rows,
+		})
+	}))
+
+	if secret := getTodoistWebhookSecret(); secret != "" {
+		routes.HandleFunc("POST "+appHomeRoute+"webhook/todoist", todoistWebhookHandler(secret))
+	}
+
+	routes.HandleFunc("GET /metrics", httputils.WrapWithErrorHandling(func(w http.ResponseWriter, r *http.Request) error {
+		usage, err := loadUsage()
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		return writeUsageMetrics(w, usage, time.Now())
+	}))
+
 	srv := &http.Server{
 		Addr:              ":" + cmp.Or(os.Getenv("PORT"), "80"),
 		Handler:           routes,
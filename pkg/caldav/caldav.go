@@ -0,0 +1,287 @@
+// CalDAV client for to-do lists (VTODO entries in a calendar collection), like Nextcloud Tasks or Apple
+// Reminders-style lists.
+//
+// https://datatracker.ietf.org/doc/html/rfc4791
+package caldav
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/function61/gokit/net/http/ezhttp"
+	"github.com/joonas-fi/shopping-list-manager/pkg/resilienthttp"
+)
+
+const (
+	StatusNeedsAction = "NEEDS-ACTION"
+	StatusCompleted   = "COMPLETED"
+)
+
+type Todo struct {
+	Href        string // path of the entry on the server (empty for new ones)
+	ETag        string
+	UID         string
+	Summary     string
+	Description string
+	Categories  []string
+	Status      string // StatusNeedsAction | StatusCompleted | ...
+
+	lines []string // of the whole calendar object, so properties we don't know about are preserved
+}
+
+type Client struct {
+	collectionURL *url.URL
+	username      string
+	password      string
+	httpClient    *http.Client
+}
+
+// collectionURL looks like "https://nextcloud.example.com/remote.php/dav/calendars/joonas/shopping/"
+func NewClient(collectionURL string, username string, password string) (*Client, error) {
+	parsed, err := url.Parse(strings.TrimSuffix(collectionURL, "/") + "/")
+	if err != nil {
+		return nil, fmt.Errorf("caldav.NewClient: %w", err)
+	}
+
+	opts := resilienthttp.DefaultOptions()
+	opts.Timeout = 10 * time.Second
+
+	return &Client{
+		collectionURL: parsed,
+		username:      username,
+		password:      password,
+		httpClient:    resilienthttp.New("caldav", opts),
+	}, nil
+}
+
+const uncompletedTodosQuery = `<?xml version="1.0" encoding="utf-8" ?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+	<D:prop>
+		<D:getetag/>
+		<C:calendar-data/>
+	</D:prop>
+	<C:filter>
+		<C:comp-filter name="VCALENDAR">
+			<C:comp-filter name="VTODO">
+				<C:prop-filter name="COMPLETED">
+					<C:is-not-defined/>
+				</C:prop-filter>
+			</C:comp-filter>
+		</C:comp-filter>
+	</C:filter>
+</C:calendar-query>`
+
+// uncompleted todos of the collection
+func (c *Client) Todos(ctx context.Context) ([]Todo, error) {
+	withErr := func(err error) ([]Todo, error) { return nil, fmt.Errorf("Todos: %w", err) }
+
+	res, err := ezhttp.Post(ctx, c.collectionURL.String(),
+		c.auth(),
+		ezhttp.Client(c.httpClient),
+		ezhttp.SendBody(strings.NewReader(uncompletedTodosQuery), "application/xml; charset=utf-8"),
+		ezhttp.Header("Depth", "1"),
+		ezhttp.After(func(conf *ezhttp.Config) { conf.Request.Method = "REPORT" }),
+	)
+	if err != nil {
+		return withErr(err)
+	}
+	defer res.Body.Close()
+
+	multistatus := struct {
+		Responses []struct {
+			Href     string `xml:"href"`
+			Propstat []struct {
+				Prop struct {
+					ETag         string `xml:"getetag"`
+					CalendarData string `xml:"calendar-data"`
+				} `xml:"prop"`
+				Status string `xml:"status"`
+			} `xml:"propstat"`
+		} `xml:"response"`
+	}{}
+	if err := xml.NewDecoder(res.Body).Decode(&multistatus); err != nil {
+		return withErr(err)
+	}
+
+	todos := []Todo{}
+	for _, response := range multistatus.Responses {
+		for _, propstat := range response.Propstat {
+			if !strings.Contains(propstat.Status, " 200 ") || propstat.Prop.CalendarData == "" {
+				continue
+			}
+
+			todo, err := parseTodo(response.Href, propstat.Prop.ETag, propstat.Prop.CalendarData)
+			if err != nil {
+				return withErr(err)
+			}
+			if todo.Status != StatusCompleted && todo.Status != "CANCELLED" { // not all clients set COMPLETED
+				todos = append(todos, *todo)
+			}
+		}
+	}
+
+	return todos, nil
+}
+
+func (c *Client) Todo(ctx context.Context, href string) (*Todo, error) {
+	withErr := func(err error) (*Todo, error) { return nil, fmt.Errorf("Todo: %w", err) }
+
+	res, err := ezhttp.Get(ctx, c.resolve(href),
+		c.auth(),
+		ezhttp.Client(c.httpClient),
+	)
+	if err != nil {
+		return withErr(err)
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return withErr(err)
+	}
+
+	todo, err := parseTodo(href, res.Header.Get("ETag"), string(data))
+	if err != nil {
+		return withErr(err)
+	}
+	return todo, nil
+}
+
+// returns the created todo (with its href)
+func (c *Client) CreateTodo(ctx context.Context, todo Todo, now time.Time) (*Todo, error) {
+	todo.UID = randomUID()
+	todo.Href = c.collectionURL.Path + todo.UID + ".ics"
+	todo.Status = StatusNeedsAction
+	todo.lines = []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//joonas-fi//shopping-list-manager//EN",
+		"BEGIN:VTODO",
+		"UID:" + todo.UID,
+		"CREATED:" + formatTime(now),
+		"END:VTODO",
+		"END:VCALENDAR",
+	}
+
+	if err := c.put(ctx, todo, now, ezhttp.Header("If-None-Match", "*")); err != nil { // don't overwrite
+		return nil, fmt.Errorf("CreateTodo: %w", err)
+	}
+
+	return &todo, nil
+}
+
+// fails if the todo was changed on the server since we got it (ETag mismatch)
+func (c *Client) UpdateTodo(ctx context.Context, todo Todo, now time.Time) error {
+	ifMatch := ezhttp.NoOpConfig
+	if todo.ETag != "" {
+		ifMatch = ezhttp.Header("If-Match", todo.ETag)
+	}
+
+	if err := c.put(ctx, todo, now, ifMatch); err != nil {
+		return fmt.Errorf("UpdateTodo: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Client) DeleteTodo(ctx context.Context, href string) error {
+	if _, err := ezhttp.Del(ctx, c.resolve(href),
+		c.auth(),
+		ezhttp.Client(c.httpClient),
+	); err != nil {
+		return fmt.Errorf("DeleteTodo: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Client) put(ctx context.Context, todo Todo, now time.Time, precondition ezhttp.ConfigPiece) error {
+	_, err := ezhttp.Put(ctx, c.resolve(todo.Href),
+		c.auth(),
+		ezhttp.Client(c.httpClient),
+		precondition,
+		ezhttp.SendBody(strings.NewReader(todo.serialize(now)), "text/calendar; charset=utf-8"),
+	)
+	return err
+}
+
+func (c *Client) auth() ezhttp.ConfigPiece {
+	if c.username == "" {
+		return ezhttp.NoOpConfig
+	}
+	return ezhttp.AuthBasic(c.username, c.password)
+}
+
+// hrefs are usually absolute paths
+func (c *Client) resolve(href string) string {
+	ref, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	return c.collectionURL.ResolveReference(ref).String()
+}
+
+func parseTodo(href string, etag string, data string) (*Todo, error) {
+	lines := unfoldLines(data)
+
+	uid, found := getProperty(lines, "UID")
+	if !found {
+		return nil, fmt.Errorf("no VTODO with UID in %s", href)
+	}
+
+	summary, _ := getProperty(lines, "SUMMARY")
+	description, _ := getProperty(lines, "DESCRIPTION")
+	categories, _ := getProperty(lines, "CATEGORIES")
+	status, _ := getProperty(lines, "STATUS")
+
+	return &Todo{
+		Href:        href,
+		ETag:        etag,
+		UID:         uid,
+		Summary:     unescapeText(summary),
+		Description: unescapeText(description),
+		Categories:  splitTextList(categories),
+		Status:      strings.ToUpper(status),
+		lines:       lines,
+	}, nil
+}
+
+// our fields are written over the original data
+func (t Todo) serialize(now time.Time) string {
+	lines := t.lines
+	lines = setProperty(lines, "SUMMARY", escapeText(t.Summary))
+	lines = setProperty(lines, "DESCRIPTION", escapeText(t.Description))
+	lines = setProperty(lines, "CATEGORIES", joinTextList(t.Categories))
+	lines = setProperty(lines, "STATUS", t.Status)
+	lines = setProperty(lines, "DTSTAMP", formatTime(now))
+	lines = setProperty(lines, "LAST-MODIFIED", formatTime(now))
+
+	if t.Status == StatusCompleted {
+		if _, alreadyCompleted := getProperty(lines, "COMPLETED"); !alreadyCompleted {
+			lines = setProperty(lines, "COMPLETED", formatTime(now))
+		}
+		lines = setProperty(lines, "PERCENT-COMPLETE", "100")
+	}
+
+	return foldLines(lines)
+}
+
+func formatTime(ts time.Time) string {
+	return ts.UTC().Format("20060102T150405Z")
+}
+
+func randomUID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
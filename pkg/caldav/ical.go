@@ -0,0 +1,180 @@
+package caldav
+
+// just enough of iCalendar (RFC 5545) for VTODO entries. properties we don't touch are preserved as-is
+// so we don't destroy data that other clients (Nextcloud Tasks, Apple Reminders..) have stored.
+
+import (
+	"strings"
+)
+
+// iCalendar content lines with folding undone
+func unfoldLines(data string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+		} else if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// lines longer than 75 octets are folded. continuation lines start with a space, which counts towards
+// their 75 octets.
+func foldLines(lines []string) string {
+	out := &strings.Builder{}
+	for _, line := range lines {
+		maxLen := 75
+		for len(line) > maxLen {
+			cut := maxLen
+			for cut > 0 && !isUTF8Start(line[cut]) { // don't split multi-byte characters
+				cut--
+			}
+			out.WriteString(line[:cut] + "\r\n ")
+			line = line[cut:]
+			maxLen = 74
+		}
+		out.WriteString(line + "\r\n")
+	}
+	return out.String()
+}
+
+func isUTF8Start(b byte) bool {
+	return b&0xc0 != 0x80
+}
+
+// "SUMMARY;LANGUAGE=fi:Maito" => "SUMMARY", "Maito".
+// parameter values can be quoted to contain ":" (`DESCRIPTION;ALTREP="http://example.com/":Maito`).
+func splitProperty(line string) (string, string) {
+	name, _, value := splitPropertyWithParams(line)
+	return name, value
+}
+
+// "SUMMARY;LANGUAGE=fi:Maito" => "SUMMARY", ";LANGUAGE=fi", "Maito"
+func splitPropertyWithParams(line string) (string, string, string) {
+	valueStart := len(line)
+	quoted := false
+	for idx := 0; idx < len(line); idx++ {
+		if line[idx] == '"' {
+			quoted = !quoted
+		} else if line[idx] == ':' && !quoted {
+			valueStart = idx
+			break
+		}
+	}
+
+	nameAndParams := line[:valueStart]
+	value := strings.TrimPrefix(line[valueStart:], ":")
+
+	paramsStart := strings.IndexByte(nameAndParams, ';')
+	if paramsStart == -1 {
+		paramsStart = len(nameAndParams)
+	}
+
+	return strings.ToUpper(nameAndParams[:paramsStart]), nameAndParams[paramsStart:], value
+}
+
+// index range of the VTODO component's properties (exclusive of BEGIN/END)
+func vtodoBounds(lines []string) (int, int, bool) {
+	begin, end := -1, -1
+	for idx, line := range lines {
+		switch strings.ToUpper(line) {
+		case "BEGIN:VTODO":
+			begin = idx
+		case "END:VTODO":
+			end = idx
+		}
+	}
+	return begin + 1, end, begin != -1 && end > begin
+}
+
+func getProperty(lines []string, name string) (string, bool) {
+	begin, end, ok := vtodoBounds(lines)
+	if !ok {
+		return "", false
+	}
+
+	for _, line := range lines[begin:end] {
+		if propName, value := splitProperty(line); propName == name {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// replaces all occurrences of the property. value "" removes the property.
+func setProperty(lines []string, name string, value string) []string {
+	begin, end, ok := vtodoBounds(lines)
+	if !ok {
+		return lines
+	}
+
+	params := "" // kept (like LANGUAGE or other clients' X- parameters) since only the value changes
+	updated := append([]string{}, lines[:begin]...)
+	for _, line := range lines[begin:end] {
+		if propName, propParams, _ := splitPropertyWithParams(line); propName != name {
+			updated = append(updated, line)
+		} else {
+			params = propParams
+		}
+	}
+	if value != "" {
+		updated = append(updated, name+params+":"+value)
+	}
+	return append(updated, lines[end:]...)
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+func escapeText(value string) string {
+	return textEscaper.Replace(value)
+}
+
+func unescapeText(value string) string {
+	out := &strings.Builder{}
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+			if value[i] == 'n' || value[i] == 'N' {
+				out.WriteByte('\n')
+			} else {
+				out.WriteByte(value[i])
+			}
+		} else {
+			out.WriteByte(value[i])
+		}
+	}
+	return out.String()
+}
+
+// splits on unescaped commas
+func splitTextList(value string) []string {
+	if value == "" {
+		return nil
+	}
+
+	items := []string{}
+	current := &strings.Builder{}
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value):
+			current.WriteString(value[i : i+2])
+			i++
+		case value[i] == ',':
+			items = append(items, unescapeText(current.String()))
+			current.Reset()
+		default:
+			current.WriteByte(value[i])
+		}
+	}
+	return append(items, unescapeText(current.String()))
+}
+
+func joinTextList(items []string) string {
+	escaped := []string{}
+	for _, item := range items {
+		escaped = append(escaped, escapeText(item))
+	}
+	return strings.Join(escaped, ",")
+}
@@ -0,0 +1,90 @@
+package caldav
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/function61/gokit/testing/assert"
+)
+
+func TestSerializePreservesUnknownProperties(t *testing.T) {
+	// as stored by some other client
+	original := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Nextcloud Tasks v0.16.1",
+		"BEGIN:VTODO",
+		"UID:abc",
+		"SUMMARY;LANGUAGE=fi;X-NC-GROUP-ID=5:Maito",
+		"CATEGORIES:Dairy & Eggs,needs-review",
+		"PRIORITY:1",
+		"X-APPLE-SORT-ORDER:7",
+		"DESCRIPTION:first line\\nsecond line with a very long text that needs to be folded",
+		"  because it's longer than 75 octets",
+		"END:VTODO",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	todo, err := parseTodo("/calendars/shopping/abc.ics", `"1"`, original)
+	assert.Ok(t, err)
+	assert.Equal(t, todo.Summary, "Maito")
+	assert.Equal(t, todo.Description, "first line\nsecond line with a very long text that needs to be folded because it's longer than 75 octets")
+	assert.Equal(t, strings.Join(todo.Categories, "|"), "Dairy & Eggs|needs-review")
+
+	todo.Summary = "Kevytmaito, 1 l"
+	todo.Categories = []string{"Dairy & Eggs"}
+	todo.Status = StatusCompleted
+
+	assert.Equal(t, todo.serialize(time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)), strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Nextcloud Tasks v0.16.1",
+		"BEGIN:VTODO",
+		"UID:abc",
+		"PRIORITY:1",
+		"X-APPLE-SORT-ORDER:7",
+		"SUMMARY;LANGUAGE=fi;X-NC-GROUP-ID=5:Kevytmaito\\, 1 l", // parameters are kept
+		"DESCRIPTION:first line\\nsecond line with a very long text that needs to be ",
+		" folded because it's longer than 75 octets",
+		"CATEGORIES:Dairy & Eggs",
+		"STATUS:COMPLETED",
+		"DTSTAMP:20251001T120000Z",
+		"LAST-MODIFIED:20251001T120000Z",
+		"COMPLETED:20251001T120000Z",
+		"PERCENT-COMPLETE:100",
+		"END:VTODO",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n"))
+}
+
+func TestTextList(t *testing.T) {
+	values := []string{"Produce (Fruits & Vegetables)", "a, b", `back\slash`}
+
+	assert.Equal(t, joinTextList(values), `Produce (Fruits & Vegetables),a\, b,back\\slash`)
+	assert.Equal(t, strings.Join(splitTextList(joinTextList(values)), "|"), `Produce (Fruits & Vegetables)|a, b|back\slash`)
+	assert.Equal(t, len(splitTextList("")), 0)
+}
+
+func TestFoldLines(t *testing.T) {
+	folded := foldLines([]string{"DESCRIPTION:" + strings.Repeat("x", 200)})
+
+	lines := strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n")
+	assert.Equal(t, len(lines), 3)
+	assert.Equal(t, len(lines[0]), 75)
+	assert.Equal(t, len(lines[1]), 75) // space + 74 octets
+	assert.Equal(t, strings.Join(unfoldLines(folded), ""), "DESCRIPTION:"+strings.Repeat("x", 200))
+}
+
+func TestSplitProperty(t *testing.T) {
+	split := func(line string) string {
+		name, value := splitProperty(line)
+		return name + " | " + value
+	}
+
+	assert.Equal(t, split("SUMMARY:Maito"), "SUMMARY | Maito")
+	assert.Equal(t, split("summary;LANGUAGE=fi:Maito: 1 l"), "SUMMARY | Maito: 1 l")
+	assert.Equal(t, split(`DESCRIPTION;ALTREP="http://example.com/maito;x=1":Maito`), "DESCRIPTION | Maito")
+	assert.Equal(t, split("BROKEN"), "BROKEN | ")
+}
@@ -7,15 +7,19 @@ package googlesearch
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/function61/gokit/net/http/ezhttp"
 	"github.com/function61/gokit/os/osutil"
+	"github.com/joonas-fi/shopping-list-manager/pkg/resilienthttp"
 )
 
 type googleCustomSearchClient struct {
 	customSearchEngineID string
 	apiKey               string
+	httpClient           *http.Client
 }
 
 func New() (*googleCustomSearchClient, error) {
@@ -29,9 +33,13 @@ func New() (*googleCustomSearchClient, error) {
 		return nil, err
 	}
 
+	opts := resilienthttp.DefaultOptions()
+	opts.Timeout = 10 * time.Second
+
 	return &googleCustomSearchClient{
 		customSearchEngineID: customSearchEngineID,
 		apiKey:               apiKey,
+		httpClient:           resilienthttp.New("googlesearch", opts),
 	}, nil
 }
 
@@ -47,7 +55,10 @@ func (g *googleCustomSearchClient) Search(ctx context.Context, query string) (*C
 	}
 
 	cs := &CustomSearch{}
-	if _, err := ezhttp.Get(ctx, "https://www.googleapis.com/customsearch/v1?"+queryParams.Encode(), ezhttp.RespondsJSONAllowUnknownFields(cs)); err != nil {
+	if _, err := ezhttp.Get(ctx, "https://www.googleapis.com/customsearch/v1?"+queryParams.Encode(),
+		ezhttp.Client(g.httpClient),
+		ezhttp.RespondsJSONAllowUnknownFields(cs),
+	); err != nil {
 		return withErr(err)
 	}
 
@@ -138,6 +149,7 @@ type Product struct {
 	Image       string `json:"image"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	Brand       string `json:"brand"`
 }
 
 type Metatag struct {
@@ -150,6 +162,7 @@ type Metatag struct {
 	YandexVerification         string `json:"yandex-verification"`
 	OgURL                      string `json:"og:url"`
 	OgDescription              string `json:"og:description"`
+	ProductBrand               string `json:"product:brand,omitempty"`
 	FormatDetection            string `json:"format-detection"`
 	AppleItunesApp             string `json:"apple-itunes-app,omitempty"`
 	NextHeadCount              string `json:"next-head-count,omitempty"`
@@ -0,0 +1,283 @@
+// Grocy client: products, barcodes, stock and shopping lists.
+//
+// https://demo.grocy.info/api
+package grocy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/function61/gokit/net/http/ezhttp"
+	"github.com/joonas-fi/shopping-list-manager/pkg/resilienthttp"
+)
+
+// older Grocy versions return IDs as strings, newer as numbers
+type ID int
+
+func (i *ID) UnmarshalJSON(data []byte) error {
+	str := strings.Trim(string(data), `"`)
+	if str == "null" || str == "" {
+		*i = 0
+		return nil
+	}
+
+	parsed, err := strconv.Atoi(str)
+	if err != nil {
+		return fmt.Errorf("grocy.ID: %w", err)
+	}
+	*i = ID(parsed)
+	return nil
+}
+
+func (i ID) String() string {
+	return strconv.Itoa(int(i))
+}
+
+type Product struct {
+	ID             ID     `json:"id"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	ProductGroupID ID     `json:"product_group_id"`
+}
+
+type ProductGroup struct {
+	ID   ID     `json:"id"`
+	Name string `json:"name"`
+}
+
+type ShoppingListItem struct {
+	ID             ID     `json:"id"`
+	ShoppingListID ID     `json:"shopping_list_id"`
+	ProductID      ID     `json:"product_id"` // 0 for free-text items
+	Note           string `json:"note"`
+	Done           ID     `json:"done"` // 0 | 1
+}
+
+// for creating products. Grocy needs to know where the product is stored and its quantity units.
+type NewProduct struct {
+	Name           string `json:"name"`
+	Description    string `json:"description,omitempty"`
+	ProductGroupID int    `json:"product_group_id,omitempty"`
+	LocationID     int    `json:"location_id"`
+	QuIDPurchase   int    `json:"qu_id_purchase"`
+	QuIDStock      int    `json:"qu_id_stock"`
+	QuIDConsume    int    `json:"qu_id_consume"`
+	QuIDPrice      int    `json:"qu_id_price"`
+}
+
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// baseURL looks like "https://grocy.example.com"
+func NewClient(baseURL string, apiKey string) *Client {
+	opts := resilienthttp.DefaultOptions()
+	opts.Timeout = 10 * time.Second
+
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: resilienthttp.New("grocy", opts),
+	}
+}
+
+// returns nil if no product has the barcode
+func (c *Client) ProductByBarcode(ctx context.Context, barcode string) (*Product, error) {
+	withErr := func(err error) (*Product, error) { return nil, fmt.Errorf("ProductByBarcode: %w", err) }
+
+	// not using "stock/products/by-barcode/<barcode>" since it responds 400 for unknown barcodes, which we
+	// couldn't tell apart from other errors
+	type productBarcode struct {
+		ProductID ID     `json:"product_id"`
+		Barcode   string `json:"barcode"`
+	}
+	barcodes, err := getObjectsWhereEqual(ctx, c, "product_barcodes", "barcode", barcode, func(b productBarcode) string { return b.Barcode })
+	if err != nil {
+		return withErr(err)
+	}
+
+	if len(barcodes) == 0 {
+		return nil, nil
+	}
+
+	product, err := c.ProductByID(ctx, barcodes[0].ProductID)
+	if err != nil {
+		return withErr(err)
+	}
+	return product, nil
+}
+
+// returns nil if there's no such product
+func (c *Client) ProductByID(ctx context.Context, id ID) (*Product, error) {
+	products, err := getObjectsWhereEqual(ctx, c, "products", "id", id.String(), func(p Product) string { return p.ID.String() })
+	if err != nil {
+		return nil, fmt.Errorf("ProductByID: %w", err)
+	}
+
+	if len(products) == 0 {
+		return nil, nil
+	}
+	return &products[0], nil
+}
+
+// returns nil if there's no such product
+func (c *Client) ProductByName(ctx context.Context, name string) (*Product, error) {
+	products, err := getObjectsWhereEqual(ctx, c, "products", "name", name, func(p Product) string { return p.Name })
+	if err != nil {
+		return nil, fmt.Errorf("ProductByName: %w", err)
+	}
+
+	if len(products) == 0 {
+		return nil, nil
+	}
+	return &products[0], nil
+}
+
+func (c *Client) ProductGroups(ctx context.Context) ([]ProductGroup, error) {
+	groups := []ProductGroup{}
+	if err := c.getObjects(ctx, "product_groups", nil, &groups); err != nil {
+		return nil, fmt.Errorf("ProductGroups: %w", err)
+	}
+	return groups, nil
+}
+
+func (c *Client) CreateProduct(ctx context.Context, product NewProduct) (ID, error) {
+	id, err := c.createObject(ctx, "products", product)
+	if err != nil {
+		return 0, fmt.Errorf("CreateProduct: %w", err)
+	}
+	return id, nil
+}
+
+func (c *Client) AddBarcode(ctx context.Context, productID ID, barcode string) error {
+	if _, err := c.createObject(ctx, "product_barcodes", map[string]any{
+		"product_id": int(productID),
+		"barcode":    barcode,
+	}); err != nil {
+		return fmt.Errorf("AddBarcode: %w", err)
+	}
+	return nil
+}
+
+// consumes one unit of the product from stock
+func (c *Client) ConsumeByBarcode(ctx context.Context, barcode string) error {
+	if _, err := ezhttp.Post(ctx, c.baseURL+"/api/stock/products/by-barcode/"+url.PathEscape(barcode)+"/consume",
+		c.auth(),
+		ezhttp.Client(c.httpClient),
+		ezhttp.SendJSON(map[string]any{
+			"amount":           1,
+			"transaction_type": "consume",
+			"spoiled":          false,
+		}),
+	); err != nil {
+		return fmt.Errorf("ConsumeByBarcode: %w", err)
+	}
+	return nil
+}
+
+// items that are not done
+func (c *Client) ShoppingListItems(ctx context.Context, shoppingListID int) ([]ShoppingListItem, error) {
+	items := []ShoppingListItem{}
+	if err := c.getObjects(ctx, "shopping_list", []string{"shopping_list_id=" + strconv.Itoa(shoppingListID), "done=0"}, &items); err != nil {
+		return nil, fmt.Errorf("ShoppingListItems: %w", err)
+	}
+	return items, nil
+}
+
+// `productID` can be 0 for a free-text item
+func (c *Client) AddShoppingListItem(ctx context.Context, shoppingListID int, productID ID, note string) (ID, error) {
+	item := map[string]any{
+		"shopping_list_id": shoppingListID,
+		"note":             note,
+		"amount":           1,
+	}
+	if productID != 0 {
+		item["product_id"] = int(productID)
+	}
+
+	id, err := c.createObject(ctx, "shopping_list", item)
+	if err != nil {
+		return 0, fmt.Errorf("AddShoppingListItem: %w", err)
+	}
+	return id, nil
+}
+
+// only the given fields are changed
+func (c *Client) UpdateShoppingListItem(ctx context.Context, id ID, fields map[string]any) error {
+	if _, err := ezhttp.Put(ctx, c.objectURL("shopping_list", id),
+		c.auth(),
+		ezhttp.Client(c.httpClient),
+		ezhttp.SendJSON(fields),
+	); err != nil {
+		return fmt.Errorf("UpdateShoppingListItem: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) DeleteShoppingListItem(ctx context.Context, id ID) error {
+	if _, err := ezhttp.Del(ctx, c.objectURL("shopping_list", id),
+		c.auth(),
+		ezhttp.Client(c.httpClient),
+	); err != nil {
+		return fmt.Errorf("DeleteShoppingListItem: %w", err)
+	}
+	return nil
+}
+
+// `query` items look like "name=Milk"
+func (c *Client) getObjects(ctx context.Context, entity string, query []string, result any) error {
+	_, err := ezhttp.Get(ctx, c.baseURL+"/api/objects/"+entity+"?"+url.Values{"query[]": query}.Encode(),
+		c.auth(),
+		ezhttp.Client(c.httpClient),
+		ezhttp.RespondsJSONAllowUnknownFields(result),
+	)
+	return err
+}
+
+// objects whose `field` is exactly `value`. Grocy's query syntax has no escaping, so values with its
+// operator characters (like "=" or "~" in URLs and product names) would change the filter's meaning.
+// those are filtered only on our side.
+func getObjectsWhereEqual[T any](ctx context.Context, c *Client, entity string, field string, value string, fieldOf func(T) string) ([]T, error) {
+	query := []string{field + "=" + value}
+	if strings.ContainsAny(value, "=<>~!§") {
+		query = nil // all objects
+	}
+
+	objects := []T{}
+	if err := c.getObjects(ctx, entity, query, &objects); err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(objects, func(object T) bool { return fieldOf(object) != value }), nil
+}
+
+func (c *Client) createObject(ctx context.Context, entity string, object any) (ID, error) {
+	res := struct {
+		CreatedObjectID ID `json:"created_object_id"`
+	}{}
+	if _, err := ezhttp.Post(ctx, c.baseURL+"/api/objects/"+entity,
+		c.auth(),
+		ezhttp.Client(c.httpClient),
+		ezhttp.SendJSON(object),
+		ezhttp.RespondsJSONAllowUnknownFields(&res),
+	); err != nil {
+		return 0, err
+	}
+	return res.CreatedObjectID, nil
+}
+
+func (c *Client) objectURL(entity string, id ID) string {
+	return c.baseURL + "/api/objects/" + entity + "/" + id.String()
+}
+
+func (c *Client) auth() ezhttp.ConfigPiece {
+	return ezhttp.Header("GROCY-API-KEY", c.apiKey)
+}
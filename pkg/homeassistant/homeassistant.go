@@ -0,0 +1,168 @@
+// Home Assistant client for to-do list entities (`todo.*` services).
+//
+// uses the REST API. WebSocket API would work as well, but since services can return responses over REST
+// (`?return_response`) we don't need it.
+//
+// https://developers.home-assistant.io/docs/api/rest/
+// https://www.home-assistant.io/integrations/todo/
+package homeassistant
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/function61/gokit/net/http/ezhttp"
+	"github.com/joonas-fi/shopping-list-manager/pkg/resilienthttp"
+)
+
+const (
+	TodoStatusNeedsAction = "needs_action"
+	TodoStatusCompleted   = "completed"
+)
+
+type TodoItem struct {
+	UID         string `json:"uid"`
+	Summary     string `json:"summary"`
+	Description string `json:"description,omitempty"`
+	Status      string `json:"status"` // TodoStatusNeedsAction | TodoStatusCompleted
+}
+
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// baseURL looks like "http://homeassistant.local:8123". token is a long-lived access token (create one in
+// your HA user profile).
+func NewClient(baseURL string, token string) *Client {
+	opts := resilienthttp.DefaultOptions()
+	opts.Timeout = 10 * time.Second
+
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+		httpClient: resilienthttp.New("homeassistant", opts),
+	}
+}
+
+// features of to-do list entities (bitmask in entity's `supported_features` attribute)
+const (
+	TodoFeatureCreateItem           = 1
+	TodoFeatureDeleteItem           = 2
+	TodoFeatureUpdateItem           = 4
+	TodoFeatureSetDescriptionOnItem = 64
+)
+
+type State struct {
+	EntityID   string         `json:"entity_id"`
+	State      string         `json:"state"`
+	Attributes map[string]any `json:"attributes"`
+}
+
+func (s State) SupportedFeatures() int {
+	features, _ := s.Attributes["supported_features"].(float64) // JSON numbers
+	return int(features)
+}
+
+func (c *Client) State(ctx context.Context, entityID string) (*State, error) {
+	state := &State{}
+	if _, err := ezhttp.Get(ctx, c.baseURL+"/api/states/"+url.PathEscape(entityID),
+		ezhttp.AuthBearer(c.token),
+		ezhttp.Client(c.httpClient),
+		ezhttp.RespondsJSONAllowUnknownFields(state),
+	); err != nil {
+		return nil, fmt.Errorf("State: %w", err)
+	}
+
+	return state, nil
+}
+
+// `status` filters items (no filters = all items)
+func (c *Client) TodoItems(ctx context.Context, entityID string, status ...string) ([]TodoItem, error) {
+	data := map[string]any{"entity_id": entityID}
+	if len(status) > 0 {
+		data["status"] = status
+	}
+
+	res := struct {
+		ServiceResponse map[string]struct {
+			Items []TodoItem `json:"items"`
+		} `json:"service_response"`
+	}{}
+	if err := c.callService(ctx, "todo", "get_items?return_response", data, &res); err != nil {
+		return nil, fmt.Errorf("TodoItems: %w", err)
+	}
+
+	return res.ServiceResponse[entityID].Items, nil
+}
+
+func (c *Client) AddTodoItem(ctx context.Context, entityID string, summary string, description string) error {
+	data := map[string]any{
+		"entity_id": entityID,
+		"item":      summary,
+	}
+	if description != "" { // not all to-do integrations support descriptions
+		data["description"] = description
+	}
+
+	if err := c.callService(ctx, "todo", "add_item", data, nil); err != nil {
+		return fmt.Errorf("AddTodoItem: %w", err)
+	}
+
+	return nil
+}
+
+// `item` is the item's UID or summary. only non-nil fields are updated.
+func (c *Client) UpdateTodoItem(ctx context.Context, entityID string, item string, rename *string, description *string, status *string) error {
+	data := map[string]any{
+		"entity_id": entityID,
+		"item":      item,
+	}
+	if rename != nil {
+		data["rename"] = *rename
+	}
+	if description != nil {
+		data["description"] = *description
+	}
+	if status != nil {
+		data["status"] = *status
+	}
+
+	if err := c.callService(ctx, "todo", "update_item", data, nil); err != nil {
+		return fmt.Errorf("UpdateTodoItem: %w", err)
+	}
+
+	return nil
+}
+
+// `item` is the item's UID or summary
+func (c *Client) RemoveTodoItem(ctx context.Context, entityID string, item string) error {
+	if err := c.callService(ctx, "todo", "remove_item", map[string]any{
+		"entity_id": entityID,
+		"item":      item,
+	}, nil); err != nil {
+		return fmt.Errorf("RemoveTodoItem: %w", err)
+	}
+
+	return nil
+}
+
+// `response` can be nil if we're not interested in it
+func (c *Client) callService(ctx context.Context, domain string, service string, data any, response any) error {
+	conf := []ezhttp.ConfigPiece{
+		ezhttp.AuthBearer(c.token),
+		ezhttp.Client(c.httpClient),
+		ezhttp.SendJSON(data),
+	}
+	if response != nil {
+		conf = append(conf, ezhttp.RespondsJSONAllowUnknownFields(response))
+	}
+
+	_, err := ezhttp.Post(ctx, c.baseURL+"/api/services/"+domain+"/"+service, conf...)
+	return err
+}
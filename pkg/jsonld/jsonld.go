@@ -0,0 +1,115 @@
+// Extracts schema.org JSON-LD structured data from HTML pages
+package jsonld
+
+import (
+	"encoding/json"
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// a JSON-LD object, like `{"@type": "Recipe", "name": "..."}`
+type Node map[string]any
+
+var scriptRe = regexp.MustCompile(`(?is)<script[^>]+type=["']?application/ld\+json["']?[^>]*>(.*?)</script>`)
+
+// extracts all JSON-LD nodes from HTML. top-level arrays and `@graph` containers are flattened.
+// invalid JSON-LD blocks are ignored (pages in the wild have plenty of them).
+func Extract(htmlDoc []byte) []Node {
+	nodes := []Node{}
+
+	for _, match := range scriptRe.FindAllSubmatch(htmlDoc, -1) {
+		var parsed any
+		if err := json.Unmarshal(match[1], &parsed); err != nil {
+			continue
+		}
+
+		nodes = append(nodes, flatten(parsed)...)
+	}
+
+	return nodes
+}
+
+// first node of the given type
+func FindType(nodes []Node, type_ string) (Node, bool) {
+	for _, node := range nodes {
+		if node.IsType(type_) {
+			return node, true
+		}
+	}
+	return nil, false
+}
+
+func flatten(item any) []Node {
+	switch item := item.(type) {
+	case []any:
+		nodes := []Node{}
+		for _, child := range item {
+			nodes = append(nodes, flatten(child)...)
+		}
+		return nodes
+	case map[string]any:
+		if graph, has := item["@graph"]; has {
+			return flatten(graph)
+		}
+		return []Node{item}
+	default:
+		return nil
+	}
+}
+
+// `@type` can be a string or an array of strings
+func (n Node) IsType(type_ string) bool {
+	return slices.Contains(n.Strings("@type"), type_)
+}
+
+// string value of a property. if the value is an object (like `"brand": {"@type": "Brand", "name": "Valio"}`),
+// its name is returned. if it's an array, the first item is returned.
+func (n Node) String(key string) string {
+	if values := n.Strings(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// string values of a property. a single value is returned as one-item slice.
+func (n Node) Strings(key string) []string {
+	return toStrings(n[key])
+}
+
+// nested object, like `"offers": {...}`. if the value is an array, the first object is returned.
+func (n Node) Node(key string) (Node, bool) {
+	switch value := n[key].(type) {
+	case map[string]any:
+		return value, true
+	case []any:
+		for _, item := range value {
+			if obj, ok := item.(map[string]any); ok {
+				return obj, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func toStrings(value any) []string {
+	switch value := value.(type) {
+	case string:
+		// JSON-LD strings in the wild often contain HTML entities
+		if text := strings.TrimSpace(html.UnescapeString(value)); text != "" {
+			return []string{text}
+		}
+		return nil
+	case []any:
+		values := []string{}
+		for _, item := range value {
+			values = append(values, toStrings(item)...)
+		}
+		return values
+	case map[string]any:
+		return toStrings(value["name"])
+	default:
+		return nil
+	}
+}
@@ -3,8 +3,15 @@ package openai
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/function61/gokit/net/http/ezhttp"
+	"github.com/joonas-fi/shopping-list-manager/pkg/resilienthttp"
 )
 
 const (
@@ -13,23 +20,146 @@ const (
 
 // NOTE: I don't recommend giving money to OpenAI: https://bsky.app/profile/joonas.fi/post/3lxwfysweu22u
 func New(apiKey string) Client {
-	return Client{
-		apiKey:  apiKey,
-		baseurl: "https://api.openai.com/v1/",
-	}
+	return NewWithBaseURL("https://api.openai.com/v1/", apiKey)
 }
 
 func NewGoogle(apiKey string) Client {
+	return NewWithBaseURL("https://generativelanguage.googleapis.com/v1beta/openai/", apiKey)
+}
+
+// for any OpenAI-compatible API, like local Ollama (`http://localhost:11434/v1/`) or llama.cpp server.
+// apiKey can be empty if the server doesn't require authentication.
+func NewWithBaseURL(baseURL string, apiKey string) Client {
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+
 	return Client{
-		apiKey:  apiKey,
-		baseurl: "https://generativelanguage.googleapis.com/v1beta/openai/",
+		apiKey:     apiKey,
+		baseurl:    baseURL,
+		httpClient: newHTTPClient(baseURL),
 	}
 }
 
+func newHTTPClient(baseURL string) *http.Client {
+	opts := resilienthttp.DefaultOptions()
+	opts.Timeout = 2 * time.Minute // local models can be slow. callers usually have a shorter deadline in ctx.
+	opts.RetryNonIdempotent = true // completions have no side effects (other than cost)
+
+	service := "openai"
+	if parsed, err := url.Parse(baseURL); err == nil { // circuit breaker per server
+		service += " " + parsed.Host
+	}
+
+	return resilienthttp.New(service, opts)
+}
+
 type ChatMessage struct {
-	Role    string  `json:"role"`
-	Content string  `json:"content"`
-	Refusal *string `json:"refusal,omitempty"`
+	Role         string        `json:"role"`
+	Content      string        `json:"content"`
+	ContentParts []ContentPart `json:"-"` // if set, sent as `content` instead of `Content` (e.g. text + images)
+	Refusal      *string       `json:"refusal,omitempty"`
+	ToolCalls    []ToolCall    `json:"tool_calls,omitempty"`   // when assistant wants to call tools
+	ToolCallID   string        `json:"tool_call_id,omitempty"` // when responding (role=tool) to a tool call
+}
+
+func (c ChatMessage) MarshalJSON() ([]byte, error) {
+	type chatMessageWithoutMethods ChatMessage // prevents infinite recursion
+
+	if len(c.ContentParts) == 0 {
+		return json.Marshal(chatMessageWithoutMethods(c))
+	}
+
+	// shadows the embedded `Content`
+	return json.Marshal(struct {
+		chatMessageWithoutMethods
+		Content []ContentPart `json:"content"`
+	}{chatMessageWithoutMethods(c), c.ContentParts})
+}
+
+// https://platform.openai.com/docs/guides/images-vision
+type ContentPart struct {
+	Type     string    `json:"type"` // "text" | "image_url"
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+type ImageURL struct {
+	URL    string `json:"url"`              // "https://..." or "data:image/jpeg;base64,..."
+	Detail string `json:"detail,omitempty"` // "low" | "high" | "auto"
+}
+
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: "text", Text: text}
+}
+
+// needs a vision-capable model
+func ImagePart(url string) ContentPart {
+	return ContentPart{Type: "image_url", ImageURL: &ImageURL{URL: url}}
+}
+
+// not all providers fetch remote image URLs, but all of them accept images inline
+func ImageDataURL(contentType string, data []byte) string {
+	return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+type ToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"` // "function"
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"` // JSON
+	} `json:"function"`
+}
+
+// https://platform.openai.com/docs/guides/function-calling
+type Tool struct {
+	Type     string             `json:"type"` // "function"
+	Function FunctionDefinition `json:"function"`
+}
+
+type FunctionDefinition struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters"` // JSON schema
+	Strict      bool   `json:"strict,omitempty"`
+}
+
+func FunctionTool(name string, description string, parametersSchema any) Tool {
+	return Tool{
+		Type: "function",
+		Function: FunctionDefinition{
+			Name:        name,
+			Description: description,
+			Parameters:  parametersSchema,
+			Strict:      true,
+		},
+	}
+}
+
+// https://platform.openai.com/docs/guides/structured-outputs
+type ResponseFormat struct {
+	Type       string      `json:"type"` // "text" | "json_object" | "json_schema"
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+type JSONSchema struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Schema      any    `json:"schema"`
+	Strict      bool   `json:"strict,omitempty"`
+}
+
+// makes the model respond with JSON that adheres to the given JSON schema
+func ResponseFormatJSONSchema(name string, schema any) *ResponseFormat {
+	return &ResponseFormat{
+		Type: "json_schema",
+		JSONSchema: &JSONSchema{
+			Name:   name,
+			Schema: schema,
+			Strict: true,
+		},
+	}
 }
 
 func SimpleChatCompletionReq(prompt string, model string) ChatCompletionReq {
@@ -49,23 +179,42 @@ func SimpleChatCompletionReq(prompt string, model string) ChatCompletionReq {
 }
 
 type ChatCompletionReq struct {
-	Model    string        `json:"model"`
-	Messages []ChatMessage `json:"messages"`
+	Model          string          `json:"model"`
+	Messages       []ChatMessage   `json:"messages"`
+	Temperature    *float64        `json:"temperature,omitempty"` // nil = provider's default
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	Tools          []Tool          `json:"tools,omitempty"`
+	ToolChoice     any             `json:"tool_choice,omitempty"` // "none" | "auto" | "required" | specific function
 }
 
 type ChatCompletionRes struct {
-	Choices []struct {
-		Message ChatMessage `json:"message"`
-	} `json:"choices"`
+	Choices []ChatCompletionChoice `json:"choices"`
+	Usage   *Usage                 `json:"usage,omitempty"` // not all OpenAI-compatible servers report it
+}
+
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type ChatCompletionChoice struct {
+	Message ChatMessage `json:"message"`
 }
 
 type Client struct {
-	baseurl string
-	apiKey  string
+	baseurl    string
+	apiKey     string
+	httpClient *http.Client
 }
 
 func (c Client) ChatCompletion(ctx context.Context, req ChatCompletionReq) (*ChatCompletionRes, error) {
+	auth := ezhttp.NoOpConfig
+	if c.apiKey != "" {
+		auth = ezhttp.AuthBearer(c.apiKey)
+	}
+
 	res := &ChatCompletionRes{}
-	_, err := ezhttp.Post(ctx, c.baseurl+"chat/completions", ezhttp.AuthBearer(c.apiKey), ezhttp.SendJSON(req), ezhttp.RespondsJSONAllowUnknownFields(res))
+	_, err := ezhttp.Post(ctx, c.baseurl+"chat/completions", auth, ezhttp.Client(c.httpClient), ezhttp.SendJSON(req), ezhttp.RespondsJSONAllowUnknownFields(res))
 	return res, err
 }
@@ -0,0 +1,26 @@
+package openai
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/function61/gokit/testing/assert"
+)
+
+func TestChatMessageMarshal(t *testing.T) {
+	marshal := func(msg ChatMessage) string {
+		asJSON, err := json.Marshal(msg)
+		assert.Ok(t, err)
+		return string(asJSON)
+	}
+
+	assert.Equal(t, marshal(ChatMessage{Role: "user", Content: "hello"}), `{"role":"user","content":"hello"}`)
+
+	assert.Equal(t, marshal(ChatMessage{
+		Role: "user",
+		ContentParts: []ContentPart{
+			TextPart("what is this?"),
+			ImagePart(ImageDataURL("image/png", []byte("fake"))),
+		},
+	}), `{"role":"user","content":[{"type":"text","text":"what is this?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,ZmFrZQ=="}}]}`)
+}
@@ -0,0 +1,149 @@
+// Resolves product details from product web pages (and GS1 Digital Links, which is what the QR
+// codes on product packages increasingly encode)
+package productpage
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/joonas-fi/shopping-list-manager/pkg/jsonld"
+	"github.com/joonas-fi/shopping-list-manager/pkg/webpage"
+)
+
+type Metadata struct {
+	Name        string
+	Description string
+	Brand       string
+	Image       string
+	GTIN        string // if the page told us (or it was a GS1 Digital Link)
+	URL         string // after redirects
+}
+
+var ErrNoProduct = errors.New("page does not have product metadata")
+
+// https://www.gs1.org/standards/gs1-digital-link
+//
+// looks like `https://id.gs1.org/01/09506000134352/10/ABC123`, where the domain can be anything
+// (brands use their own). "01" is the GTIN's application identifier.
+var gs1DigitalLinkGTINRe = regexp.MustCompile(`/01/([0-9]{8,14})(?:/|$)`)
+
+// returns the GTIN in the form found on product packages' barcodes (EAN-13 / UPC-A / EAN-8).
+// GS1 Digital Links carry GTINs zero-padded to 14 digits.
+func ParseGS1DigitalLink(link string) (string, bool) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", false
+	}
+
+	match := gs1DigitalLinkGTINRe.FindStringSubmatch(u.Path)
+	if match == nil {
+		return "", false
+	}
+
+	gtin := match[1]
+	// zero-padded GTIN-14 => EAN-13 (UPC-A becomes its EAN-13 form, which is also what search engines know)
+	if len(gtin) == 14 && strings.HasPrefix(gtin, "0") {
+		gtin = gtin[1:]
+	}
+
+	return gtin, true
+}
+
+// product metadata from an already fetched page. if the redirects led to a GS1 Digital Link, only its GTIN
+// is returned (the link need not point to a working page).
+func FromPage(page *webpage.Page) (*Metadata, error) {
+	withErr := func(err error) (*Metadata, error) { return nil, fmt.Errorf("productpage.FromPage: %w", err) }
+
+	if gtin, ok := ParseGS1DigitalLink(page.URL); ok {
+		return &Metadata{GTIN: gtin, URL: page.URL}, nil
+	}
+
+	if !page.OK() {
+		return withErr(fmt.Errorf("%s: %s", page.URL, page.Status))
+	}
+
+	metadata, err := Parse(page.Body)
+	if err != nil {
+		return withErr(err)
+	}
+
+	metadata.URL = page.URL
+
+	return metadata, nil
+}
+
+// extracts product metadata from schema.org `Product` JSON-LD, falling back to OpenGraph tags
+func Parse(htmlDoc []byte) (*Metadata, error) {
+	if product, found := jsonld.FindType(jsonld.Extract(htmlDoc), "Product"); found && product.String("name") != "" {
+		metadata := &Metadata{
+			Name:        product.String("name"),
+			Description: product.String("description"),
+			Brand:       product.String("brand"),
+			GTIN:        firstNonEmpty(product.String("gtin13"), product.String("gtin"), product.String("gtin12"), product.String("gtin8"), product.String("gtin14")),
+		}
+
+		if image, isObject := product.Node("image"); isObject { // ImageObject
+			metadata.Image = image.String("url")
+		} else {
+			metadata.Image = product.String("image")
+		}
+
+		return metadata, nil
+	}
+
+	og := openGraphTags(htmlDoc)
+
+	// OpenGraph is used by all kinds of pages, so insist on the type to not mistake any page for a product
+	if ogType := og["og:type"]; (ogType == "product" || ogType == "og:product") && og["og:title"] != "" {
+		return &Metadata{
+			Name:        og["og:title"],
+			Description: og["og:description"],
+			Brand:       og["product:brand"],
+			Image:       og["og:image"],
+		}, nil
+	}
+
+	return nil, ErrNoProduct
+}
+
+var (
+	metaTagRe   = regexp.MustCompile(`(?i)<meta\s[^>]*>`)
+	attributeRe = regexp.MustCompile(`([a-zA-Z:_-]+)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+)
+
+// `<meta property="og:title" content="Foo">` => {"og:title": "Foo"}
+func openGraphTags(htmlDoc []byte) map[string]string {
+	tags := map[string]string{}
+
+	for _, metaTag := range metaTagRe.FindAll(htmlDoc, -1) {
+		attributes := map[string]string{}
+		for _, attr := range attributeRe.FindAllSubmatch(metaTag, -1) {
+			attributes[strings.ToLower(string(attr[1]))] = html.UnescapeString(string(attr[2]) + string(attr[3]))
+		}
+
+		// OpenGraph uses "property", but "name" is often used by mistake
+		key := firstNonEmpty(attributes["property"], attributes["name"])
+		if key == "" {
+			continue
+		}
+
+		if _, alreadySet := tags[key]; !alreadySet { // first one wins
+			tags[key] = strings.TrimSpace(attributes["content"])
+		}
+	}
+
+	return tags
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,46 @@
+package productpage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/function61/gokit/testing/assert"
+)
+
+func TestParseGS1DigitalLink(t *testing.T) {
+	parse := func(link string) string {
+		gtin, ok := ParseGS1DigitalLink(link)
+		if !ok {
+			return "not a GS1 Digital Link"
+		}
+		return gtin
+	}
+
+	assert.Equal(t, parse("https://id.gs1.org/01/06408430000258"), "6408430000258")
+	assert.Equal(t, parse("https://id.gs1.org/01/06408430000258/10/ABC123?17=261231"), "6408430000258")
+	assert.Equal(t, parse("https://brand.example.com/01/16408430000255/21/12345"), "16408430000255")
+	assert.Equal(t, parse("https://brand.example.com/01/96385074"), "96385074")
+	assert.Equal(t, parse("https://xs.fi/0/UJNyJmk"), "not a GS1 Digital Link")
+	assert.Equal(t, parse("https://example.com/products/01/abc"), "not a GS1 Digital Link")
+}
+
+func TestParse(t *testing.T) {
+	parse := func(fixture string) string {
+		htmlDoc, err := os.ReadFile(fixture)
+		assert.Ok(t, err)
+
+		metadata, err := Parse(htmlDoc)
+		if err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("%s | %s | %s | %s | %s", metadata.Name, metadata.Brand, metadata.Description, metadata.Image, metadata.GTIN)
+	}
+
+	assert.Equal(t, parse("testdata/product-jsonld.html"), "Valio Eila® laktoositon kevytmaitojuoma 1 l | Valio | Laktoositon kevytmaitojuoma | https://www.valio.example.com/eila.jpg | 6408430000258")
+	assert.Equal(t, parse("testdata/product-opengraph.html"), "Fazer Sininen maitosuklaa 200 g |  | Fazer Sininen – maidoton? Ei, vaan maitosuklaa. | https://fazer.example.com/sininen.jpg | ")
+
+	_, err := Parse([]byte(`<html><head><meta property="og:type" content="website"><meta property="og:title" content="Blog"></head></html>`))
+	assert.Equal(t, errors.Is(err, ErrNoProduct), true)
+}
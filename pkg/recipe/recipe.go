@@ -0,0 +1,62 @@
+// Extracts recipe ingredients from recipe web pages (using the schema.org `Recipe` JSON-LD that
+// recipe sites embed for search engines)
+package recipe
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/joonas-fi/shopping-list-manager/pkg/jsonld"
+	"github.com/joonas-fi/shopping-list-manager/pkg/webpage"
+)
+
+// https://schema.org/Recipe
+type Recipe struct {
+	Name        string
+	Ingredients []string // as written in the recipe, like "2 dl kermaa"
+	URL         string
+}
+
+var ErrNoRecipe = errors.New("page does not have schema.org Recipe data")
+
+// recipe from an already fetched page
+func FromPage(page *webpage.Page) (*Recipe, error) {
+	withErr := func(err error) (*Recipe, error) { return nil, fmt.Errorf("recipe.FromPage: %w", err) }
+
+	if !page.OK() { // like a bot-blocking product page. not our job to tell it apart from a broken recipe link.
+		return withErr(fmt.Errorf("%w (%s: %s)", ErrNoRecipe, page.URL, page.Status))
+	}
+
+	recipe, err := Parse(page.Body)
+	if err != nil {
+		return withErr(err)
+	}
+
+	if recipe.URL == "" {
+		recipe.URL = page.URL // after redirects
+	}
+
+	return recipe, nil
+}
+
+func Parse(htmlDoc []byte) (*Recipe, error) {
+	node, found := jsonld.FindType(jsonld.Extract(htmlDoc), "Recipe")
+	if !found {
+		return nil, ErrNoRecipe
+	}
+
+	ingredients := node.Strings("recipeIngredient")
+	if len(ingredients) == 0 { // deprecated property, still used by some sites
+		ingredients = node.Strings("ingredients")
+	}
+
+	if len(ingredients) == 0 {
+		return nil, fmt.Errorf("%w (Recipe has no ingredients)", ErrNoRecipe)
+	}
+
+	return &Recipe{
+		Name:        node.String("name"),
+		Ingredients: ingredients,
+		URL:         node.String("url"),
+	}, nil
+}
@@ -0,0 +1,38 @@
+package recipe
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/function61/gokit/testing/assert"
+)
+
+func TestParse(t *testing.T) {
+	recipe, err := Parse(readFixture(t, "testdata/recipe-graph.html"))
+	assert.Ok(t, err)
+
+	assert.Equal(t, recipe.Name, "Kermainen lohikeitto")
+	assert.Equal(t, recipe.URL, "https://reseptit.example.com/kermainen-lohikeitto")
+	assert.Equal(t, strings.Join(recipe.Ingredients, "\n"), `400 g lohifileetä
+6 perunaa
+1 purjo
+2 dl ruokakermaa
+1 nippu tilliä
+suolaa & pippuria`)
+}
+
+func TestParseNoRecipe(t *testing.T) {
+	_, err := Parse(readFixture(t, "testdata/no-recipe.html"))
+	assert.Equal(t, errors.Is(err, ErrNoRecipe), true)
+}
+
+func readFixture(t *testing.T, path string) []byte {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	assert.Ok(t, err)
+
+	return content
+}
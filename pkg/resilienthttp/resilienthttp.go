@@ -0,0 +1,295 @@
+// Resilient HTTP client for external services: per-service timeouts, retries with exponential backoff
+// (honoring `Retry-After`) and a circuit breaker that short-circuits a failing service. Plugs into
+// `ezhttp` with `ezhttp.Client(resilienthttp.New(...))`.
+package resilienthttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/function61/gokit/app/backoff"
+)
+
+var (
+	ErrRateLimited = errors.New("rate limited")
+	ErrUnavailable = errors.New("service unavailable") // 5xx, connection errors or circuit open
+	ErrTimeout     = errors.New("timed out")
+	ErrCircuitOpen = errors.New("circuit open (too many recent failures)")
+)
+
+// error of a request that failed even after retries. `errors.Is()` works with the error kinds above.
+type Error struct {
+	Service    string
+	Kind       error // one of the `Err...` above
+	StatusCode int   // 0 if the request didn't get a response
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %v: %v", e.Service, e.Kind, e.Err)
+}
+
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+type Options struct {
+	Timeout          time.Duration // for each attempt
+	MaxAttempts      int
+	BackoffBase      time.Duration
+	BackoffMax       time.Duration // also the longest `Retry-After` we're willing to wait
+	BreakerThreshold int           // consecutive failures that open the circuit
+	BreakerCooldown  time.Duration // how long the circuit stays open before letting a trial request through
+	// POST etc. are retried only on 429 by default, since otherwise the server might have processed the
+	// request already. set this for requests that are safe to repeat (like queries implemented as POST).
+	RetryNonIdempotent bool
+}
+
+// suitable for interactive use (someone is waiting for the beep to be processed)
+func DefaultOptions() Options {
+	return Options{
+		Timeout:          15 * time.Second,
+		MaxAttempts:      3,
+		BackoffBase:      500 * time.Millisecond,
+		BackoffMax:       10 * time.Second,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// returns a client for talking to `service`. clients of the same service share the circuit breaker.
+func New(service string, opts Options) *http.Client {
+	return &http.Client{
+		Transport: &transport{
+			service: service,
+			opts:    opts,
+			inner:   http.DefaultTransport,
+			breaker: breakerFor(service, opts),
+			sleep:   sleep,
+		},
+	}
+}
+
+type transport struct {
+	service string
+	opts    Options
+	inner   http.RoundTripper
+	breaker *breaker
+	sleep   func(ctx context.Context, duration time.Duration) error // overridable for tests
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	retryBackoff := backoff.ExponentialWithCappedMax(t.opts.BackoffBase, t.opts.BackoffMax)
+	_ = retryBackoff() // first one is zero
+
+	canRepeat := req.Body == nil || req.GetBody != nil // need to be able to send the body again
+
+	for attempt := 1; ; attempt++ {
+		if !t.breaker.Allow(time.Now()) {
+			return nil, &Error{Service: t.service, Kind: ErrUnavailable, Err: ErrCircuitOpen}
+		}
+
+		res, err := t.attempt(req, attempt)
+		failure := t.classify(ctx, res, err)
+		t.breaker.Record(failure == nil || errors.Is(failure.Kind, ErrRateLimited), time.Now())
+
+		if failure == nil { // success, or an error that retrying won't help (like 404)
+			return res, err
+		}
+
+		wait := retryBackoff()
+		if retryAfter, ok := parseRetryAfter(res, time.Now()); ok {
+			wait = retryAfter
+		}
+
+		retryable := canRepeat &&
+			attempt < t.opts.MaxAttempts &&
+			wait <= t.opts.BackoffMax &&
+			(failure.StatusCode == http.StatusTooManyRequests || t.opts.RetryNonIdempotent || isIdempotent(req)) &&
+			ctx.Err() == nil
+
+		if res != nil { // we're not giving the response to the caller
+			_ = res.Body.Close()
+		}
+
+		if !retryable {
+			return nil, failure
+		}
+
+		if err := t.sleep(ctx, wait); err != nil {
+			return nil, failure
+		}
+	}
+}
+
+func (t *transport) attempt(req *http.Request, attempt int) (*http.Response, error) {
+	if attempt > 1 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.opts.Timeout)
+
+	res, err := t.inner.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// the timeout needs to cover reading the body too
+	res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+
+	return res, nil
+}
+
+// nil if the attempt should not be retried
+func (t *transport) classify(ctx context.Context, res *http.Response, err error) *Error {
+	if err != nil {
+		if ctx.Err() != nil { // caller gave up. not the service's fault.
+			return nil
+		}
+
+		if errors.Is(err, context.DeadlineExceeded) {
+			return &Error{Service: t.service, Kind: ErrTimeout, Err: err}
+		}
+
+		return &Error{Service: t.service, Kind: ErrUnavailable, Err: err}
+	}
+
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests:
+		return &Error{Service: t.service, Kind: ErrRateLimited, StatusCode: res.StatusCode, Err: errors.New(res.Status)}
+	case res.StatusCode == http.StatusRequestTimeout || res.StatusCode == http.StatusGatewayTimeout:
+		return &Error{Service: t.service, Kind: ErrTimeout, StatusCode: res.StatusCode, Err: errors.New(res.Status)}
+	case res.StatusCode >= 500:
+		return &Error{Service: t.service, Kind: ErrUnavailable, StatusCode: res.StatusCode, Err: errors.New(res.Status)}
+	default:
+		return nil
+	}
+}
+
+// https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Retry-After
+func parseRetryAfter(res *http.Response, now time.Time) (time.Duration, bool) {
+	if res == nil {
+		return 0, false
+	}
+
+	retryAfter := res.Header.Get("Retry-After")
+	if retryAfter == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(retryAfter); err == nil {
+		return max(0, time.Duration(seconds)*time.Second), true
+	}
+
+	if ts, err := http.ParseTime(retryAfter); err == nil {
+		return max(0, ts.Sub(now)), true
+	}
+
+	return 0, false
+}
+
+// https://developer.mozilla.org/en-US/docs/Glossary/Idempotent
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default: // some APIs de-duplicate with a request ID
+		return req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Request-Id") != ""
+	}
+}
+
+func sleep(ctx context.Context, duration time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(duration):
+		return nil
+	}
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// circuit breaker: after `threshold` consecutive failures, requests fail fast for `cooldown`.
+// after that one trial request is let through, whose outcome closes or re-opens the circuit.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu                  sync.Mutex
+	consecutiveFailures int
+	openUntil           time.Time
+	trialInFlight       bool
+}
+
+func (b *breaker) Allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 || b.consecutiveFailures < b.threshold { // disabled or closed
+		return true
+	}
+
+	if now.Before(b.openUntil) || b.trialInFlight { // open
+		return false
+	}
+
+	b.trialInFlight = true // half-open
+
+	return true
+}
+
+func (b *breaker) Record(success bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trialInFlight = false
+
+	if success {
+		b.consecutiveFailures = 0
+		return
+	}
+
+	b.consecutiveFailures++
+
+	if b.consecutiveFailures >= b.threshold {
+		b.openUntil = now.Add(b.cooldown)
+	}
+}
+
+var (
+	breakers   = map[string]*breaker{}
+	breakersMu sync.Mutex
+)
+
+func breakerFor(service string, opts Options) *breaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+
+	if _, found := breakers[service]; !found {
+		breakers[service] = &breaker{threshold: opts.BreakerThreshold, cooldown: opts.BreakerCooldown}
+	}
+
+	return breakers[service]
+}
@@ -0,0 +1,130 @@
+package resilienthttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/function61/gokit/net/http/ezhttp"
+	"github.com/function61/gokit/testing/assert"
+)
+
+// server responds with given status codes in order (200 after they run out)
+func testServer(t *testing.T, statusCodes ...int) (*httptest.Server, *int) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if len(statusCodes) >= requests {
+			if statusCodes[requests-1] == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "1")
+			}
+			w.WriteHeader(statusCodes[requests-1])
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func testClient(t *testing.T, opts Options) (*http.Client, *[]time.Duration) {
+	client := New(t.Name(), opts)
+
+	slept := []time.Duration{}
+	client.Transport.(*transport).sleep = func(_ context.Context, duration time.Duration) error {
+		slept = append(slept, duration)
+		return nil
+	}
+
+	return client, &slept
+}
+
+func TestRetriesTransientErrors(t *testing.T) {
+	srv, requests := testServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	client, slept := testClient(t, DefaultOptions())
+
+	_, err := ezhttp.Get(context.Background(), srv.URL, ezhttp.Client(client))
+	assert.Ok(t, err)
+	assert.Equal(t, *requests, 3)
+	assert.Equal(t, len(*slept), 2)
+	assert.Equal(t, (*slept)[0], 500*time.Millisecond)
+	assert.Equal(t, (*slept)[1], 1*time.Second) // from `Retry-After`
+}
+
+func TestGivesUpWithTypedError(t *testing.T) {
+	srv, requests := testServer(t, http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests)
+	client, _ := testClient(t, DefaultOptions())
+
+	_, err := ezhttp.Get(context.Background(), srv.URL, ezhttp.Client(client))
+	assert.Equal(t, errors.Is(err, ErrRateLimited), true)
+	assert.Equal(t, *requests, 3)
+
+	var typedErr *Error
+	assert.Equal(t, errors.As(err, &typedErr), true)
+	assert.Equal(t, typedErr.StatusCode, http.StatusTooManyRequests)
+}
+
+func TestDoesNotRetryNonIdempotent(t *testing.T) {
+	srv, requests := testServer(t, http.StatusInternalServerError)
+	client, _ := testClient(t, DefaultOptions())
+
+	_, err := ezhttp.Post(context.Background(), srv.URL, ezhttp.Client(client), ezhttp.SendJSON("hello"))
+	assert.Equal(t, errors.Is(err, ErrUnavailable), true)
+	assert.Equal(t, *requests, 1)
+}
+
+func TestClientErrorsAreNotRetried(t *testing.T) {
+	srv, requests := testServer(t, http.StatusNotFound)
+	client, _ := testClient(t, DefaultOptions())
+
+	_, err := ezhttp.Get(context.Background(), srv.URL, ezhttp.Client(client))
+	assert.Equal(t, ezhttp.ErrorIs(err, http.StatusNotFound), true)
+	assert.Equal(t, *requests, 1)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	srv, requests := testServer(t, http.StatusBadGateway, http.StatusBadGateway)
+
+	opts := DefaultOptions()
+	opts.MaxAttempts = 1
+	opts.BreakerThreshold = 2
+	client, _ := testClient(t, opts)
+
+	get := func() error {
+		_, err := ezhttp.Get(context.Background(), srv.URL, ezhttp.Client(client))
+		return err
+	}
+
+	assert.Equal(t, errors.Is(get(), ErrCircuitOpen), false)
+	assert.Equal(t, errors.Is(get(), ErrCircuitOpen), false)
+	assert.Equal(t, errors.Is(get(), ErrCircuitOpen), true) // short-circuited
+	assert.Equal(t, errors.Is(get(), ErrUnavailable), true)
+	assert.Equal(t, *requests, 2)
+
+	// cooldown over => trial request succeeds and closes the circuit
+	breakerFor(t.Name(), opts).openUntil = time.Now()
+	assert.Ok(t, get())
+	assert.Ok(t, get())
+	assert.Equal(t, *requests, 4)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 9, 30, 12, 0, 0, 0, time.UTC)
+
+	retryAfter := func(value string) string {
+		duration, ok := parseRetryAfter(&http.Response{Header: http.Header{"Retry-After": []string{value}}}, now)
+		if !ok {
+			return "invalid"
+		}
+		return duration.String()
+	}
+
+	assert.Equal(t, retryAfter("3"), "3s")
+	assert.Equal(t, retryAfter("Tue, 30 Sep 2025 12:01:00 GMT"), "1m0s")
+	assert.Equal(t, retryAfter("Tue, 30 Sep 2025 11:00:00 GMT"), "0s")
+	assert.Equal(t, retryAfter(strings.Repeat("x", 3)), "invalid")
+}
@@ -0,0 +1,153 @@
+package todoist
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/function61/gokit/net/http/ezhttp"
+)
+
+// local mirror of (uncompleted) tasks, kept up to date incrementally with the Sync API's sync tokens.
+// this way looking up whether an item is on the list doesn't need to list the whole project every time.
+//
+// https://developer.todoist.com/api/v1/#tag/Sync
+type mirror struct {
+	syncToken string // "" = not synced yet (= full sync needed)
+	tasks     map[string]Task
+	synced    time.Time
+	mu        sync.Mutex
+}
+
+// item as seen in Sync API. like REST API's task, except completed and deleted tasks are reported (to
+// incremental syncs) so we know to drop them. not `Task` since some fields are differently typed here.
+type syncItem struct {
+	ID          string     `json:"id"`
+	ChildOrder  int        `json:"child_order"`
+	Content     string     `json:"content"`
+	Description string     `json:"description"`
+	AddedAt     time.Time  `json:"added_at"`
+	Due         *DueSpec   `json:"due"`
+	ProjectID   string     `json:"project_id"`
+	SectionID   string     `json:"section_id"`
+	Labels      []string   `json:"labels"`
+	Checked     bool       `json:"checked"`
+	CompletedAt *time.Time `json:"completed_at"`
+	IsDeleted   bool       `json:"is_deleted"`
+}
+
+func (s syncItem) Task() Task {
+	return Task{
+		ID:          s.ID,
+		ChildOrder:  s.ChildOrder,
+		Content:     s.Content,
+		Description: s.Description,
+		AddedAt:     s.AddedAt,
+		Due:         s.Due,
+		ProjectID:   s.ProjectID,
+		SectionID:   s.SectionID,
+		Labels:      s.Labels,
+	}
+}
+
+type syncResponse struct {
+	SyncToken string     `json:"sync_token"`
+	FullSync  bool       `json:"full_sync"`
+	Items     []syncItem `json:"items"`
+}
+
+// tasks of a project from the local mirror. the mirror is synced first if it's older than `maxAge`
+// (which is cheap after the first sync since we only get the changes). use 0 to force a sync.
+func (t *Client) MirroredTasksByProject(ctx context.Context, projectID string, maxAge time.Duration) ([]Task, error) {
+	t.mirror.mu.Lock()
+	defer t.mirror.mu.Unlock()
+
+	if t.mirror.syncToken == "" || time.Since(t.mirror.synced) >= maxAge {
+		if err := t.syncMirror(ctx); err != nil {
+			return nil, fmt.Errorf("MirroredTasksByProject: %w", err)
+		}
+	}
+
+	tasks := []Task{}
+	for _, task := range t.mirror.tasks {
+		if task.ProjectID == projectID {
+			tasks = append(tasks, task)
+		}
+	}
+
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].ChildOrder != tasks[j].ChildOrder {
+			return tasks[i].ChildOrder < tasks[j].ChildOrder
+		}
+		return tasks[i].ID < tasks[j].ID // tasks created by us locally have no order yet
+	})
+
+	return tasks, nil
+}
+
+// caller must hold the lock
+func (t *Client) syncMirror(ctx context.Context) error {
+	syncToken := t.mirror.syncToken
+	if syncToken == "" {
+		syncToken = "*" // full sync
+	}
+
+	res := syncResponse{}
+	if _, err := ezhttp.Post(ctx, t.baseURL+"sync",
+		ezhttp.AuthBearer(t.token),
+		ezhttp.Client(t.httpClient),
+		ezhttp.SendBody(strings.NewReader(url.Values{
+			"sync_token":     {syncToken},
+			"resource_types": {`["items"]`},
+		}.Encode()), "application/x-www-form-urlencoded"),
+		ezhttp.RespondsJSONAllowUnknownFields(&res),
+	); err != nil {
+		return err
+	}
+
+	if res.FullSync || t.mirror.tasks == nil { // server can decide to do full sync even if we asked for incremental
+		t.mirror.tasks = map[string]Task{}
+	}
+
+	for _, item := range res.Items {
+		if item.Checked || item.CompletedAt != nil || item.IsDeleted {
+			delete(t.mirror.tasks, item.ID)
+		} else {
+			t.mirror.tasks[item.ID] = item.Task()
+		}
+	}
+
+	t.mirror.syncToken = res.SyncToken
+	t.mirror.synced = time.Now()
+
+	return nil
+}
+
+// our own changes are applied to the mirror right away, so reading the list right after a change doesn't
+// need a sync to see it
+func (m *mirror) update(taskID string, apply func(task *Task)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.tasks == nil { // not in use
+		return
+	}
+
+	task, found := m.tasks[taskID]
+	if !found {
+		task = Task{ID: taskID}
+	}
+	apply(&task)
+	m.tasks[taskID] = task
+}
+
+func (m *mirror) remove(taskID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.tasks, taskID)
+}
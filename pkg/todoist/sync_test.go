@@ -0,0 +1,72 @@
+package todoist
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/function61/gokit/testing/assert"
+)
+
+func TestMirroredTasksByProject(t *testing.T) {
+	// sync token => response
+	responses := map[string]string{
+		"*": `{"sync_token": "t1", "full_sync": true, "items": [
+			{"id": "1", "project_id": "123", "content": "milk", "child_order": 2},
+			{"id": "2", "project_id": "123", "content": "bread", "child_order": 1},
+			{"id": "3", "project_id": "456", "content": "other project", "due": {"date": "2025-10-01T12:00:00Z", "is_recurring": false}},
+			{"id": "6", "project_id": "123", "content": "cheese", "child_order": 4, "added_at": "2025-09-30T08:00:00.000000Z", "due": {"date": "2025-10-01", "is_recurring": false}}
+		]}`,
+		"t1": `{"sync_token": "t2", "full_sync": false, "items": [
+			{"id": "1", "project_id": "123", "content": "milk", "checked": true, "completed_at": "2025-10-01T12:00:00.000000Z"},
+			{"id": "6", "project_id": "123", "content": "cheese", "is_deleted": true},
+			{"id": "4", "project_id": "123", "content": "eggs", "child_order": 3}
+		]}`,
+		"t2": `{"sync_token": "t2", "full_sync": false, "items": []}`,
+	}
+
+	syncTokens := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sync":
+			assert.Equal(t, r.FormValue("resource_types"), `["items"]`)
+			syncTokens = append(syncTokens, r.FormValue("sync_token"))
+			_, _ = w.Write([]byte(responses[r.FormValue("sync_token")]))
+		case "/tasks":
+			_, _ = w.Write([]byte(`{"id": "5", "project_id": "123", "content": "butter"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	todo := &Client{token: "dummy", httpClient: srv.Client(), baseURL: srv.URL + "/"}
+
+	contents := func(maxAge time.Duration) string {
+		tasks, err := todo.MirroredTasksByProject(context.Background(), "123", maxAge)
+		assert.Ok(t, err)
+
+		names := []string{}
+		for _, task := range tasks {
+			names = append(names, task.Content)
+		}
+		return strings.Join(names, ",")
+	}
+
+	assert.Equal(t, contents(time.Minute), "bread,milk,cheese")
+	assert.Equal(t, contents(time.Minute), "bread,milk,cheese") // from mirror
+
+	created, err := todo.CreateTask(context.Background(), Task{Content: "butter", ProjectID: "123"})
+	assert.Ok(t, err)
+	assert.Equal(t, created.ID, "5")
+	assert.Equal(t, contents(time.Minute), "butter,bread,milk,cheese") // own change is visible without a sync
+
+	assert.Equal(t, contents(0), "butter,bread,eggs")
+	assert.Equal(t, contents(0), "butter,bread,eggs")
+
+	assert.Equal(t, fmt.Sprintf("%v", syncTokens), "[* t1 t2]")
+}
@@ -3,11 +3,16 @@ package todoist
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"net/http"
+	"net/url"
 	"sort"
 	"time"
 
 	"github.com/function61/gokit/net/http/ezhttp"
+	"github.com/joonas-fi/shopping-list-manager/pkg/resilienthttp"
 )
 
 // https://developer.todoist.com/api/v1/
@@ -18,17 +23,32 @@ import (
 // }
 
 type Task struct {
-	ID          string    `json:"id"`
-	Order       int       `json:"order,omitempty"`       // (ONLY USED WHEN CREATING - GENIUS DESIGN!!!!) order within this project. on creation need omitempty to not set 0 (= which would be first on list).
-	ChildOrder  int       `json:"child_order,omitempty"` // (ONLY USED WHEN LISTING  - GENIUS DESIGN!!!!) order within this project (named "child" even though the perspective is this task, more apt would've been "order_in_parent").
-	Content     string    `json:"content"`
-	Description string    `json:"description"`
-	CompletedAt bool      `json:"completed_at,omitempty"`
-	AddedAt     time.Time `json:"added_at,omitempty"`
+	ID          string     `json:"id"`
+	Order       int        `json:"order,omitempty"`       // (ONLY USED WHEN CREATING - GENIUS DESIGN!!!!) order within this project. on creation need omitempty to not set 0 (= which would be first on list).
+	ChildOrder  int        `json:"child_order,omitempty"` // (ONLY USED WHEN LISTING  - GENIUS DESIGN!!!!) order within this project (named "child" even though the perspective is this task, more apt would've been "order_in_parent").
+	Content     string     `json:"content"`
+	Description string     `json:"description"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	AddedAt     time.Time  `json:"added_at,omitempty"`
 	// URL         string    `json:"url"`
 	Due *DueSpec `json:"due"` // only present for ones that have due date
 
 	ProjectID string `json:"project_id"`
+	SectionID string `json:"section_id,omitempty"` // can't be changed by updating. use `MoveTask()`.
+
+	Labels []string `json:"labels"` // names of labels
+}
+
+type Label struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+type Section struct {
+	ID        string `json:"id,omitempty"`
+	ProjectID string `json:"project_id"`
+	Name      string `json:"name"`
+	Order     int    `json:"section_order,omitempty"`
 }
 
 // returns 0 if no due date
@@ -56,12 +76,79 @@ type paginated[T any] struct {
 	NextCursor *string `json:"next_cursor"`
 }
 
+const (
+	// safety cap so a misbehaving cursor can't keep us looping forever. with 200 items/page this is plenty
+	// for a shopping list.
+	maxPages = 50
+)
+
+// fetches all pages of a list endpoint (tasks, projects, sections, labels, comments..) by following
+// `next_cursor`. `endpoint` is relative to the API base URL and can have query params.
+func getAllPages[T any](ctx context.Context, t *Client, endpoint string) ([]T, error) {
+	all := []T{}
+	seenCursors := map[string]bool{}
+
+	cursor := ""
+	for page := 1; ; page++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if page > maxPages {
+			return nil, fmt.Errorf("more than %d pages of results", maxPages)
+		}
+
+		pageURL, err := url.Parse(t.baseURL + endpoint)
+		if err != nil {
+			return nil, err
+		}
+		if cursor != "" {
+			query := pageURL.Query()
+			query.Set("cursor", cursor)
+			pageURL.RawQuery = query.Encode()
+		}
+
+		res := paginated[T]{}
+		if _, err := ezhttp.Get(ctx, pageURL.String(),
+			ezhttp.AuthBearer(t.token),
+			ezhttp.Client(t.httpClient),
+			ezhttp.RespondsJSONAllowUnknownFields(&res),
+		); err != nil {
+			return nil, err
+		}
+
+		all = append(all, res.Results...)
+
+		if res.NextCursor == nil || *res.NextCursor == "" { // last page
+			return all, nil
+		}
+
+		cursor = *res.NextCursor
+		if seenCursors[cursor] {
+			return nil, fmt.Errorf("got the same cursor twice: %s", cursor)
+		}
+		seenCursors[cursor] = true
+	}
+}
+
 func NewClient(token string) *Client {
-	return &Client{token}
+	opts := resilienthttp.DefaultOptions()
+	opts.Timeout = 10 * time.Second
+
+	return &Client{
+		token:      token,
+		httpClient: resilienthttp.New("todoist", opts),
+		baseURL:    apiBaseURL,
+	}
 }
 
+const apiBaseURL = "https://api.todoist.com/api/v1/"
+
 type Client struct {
-	token string
+	token      string
+	httpClient *http.Client
+	baseURL    string
+	mirror     mirror
 }
 
 // func (t *Client) Project(ctx context.Context, id int64) (*Project, error) {
@@ -78,21 +165,14 @@ type Client struct {
 // }
 
 func (t *Client) TasksByProject(ctx context.Context, projectID string, now time.Time) ([]Task, error) {
-	tasksPaginated := paginated[Task]{}
-
-	if _, err := ezhttp.Get(ctx, fmt.Sprintf("https://api.todoist.com/api/v1/tasks?project_id=%s&limit=200", projectID),
-		ezhttp.AuthBearer(t.token),
-		ezhttp.RespondsJSONAllowUnknownFields(&tasksPaginated),
-	); err != nil {
+	tasks, err := getAllPages[Task](ctx, t, "tasks?"+url.Values{
+		"project_id": {projectID},
+		"limit":      {"200"}, // max allowed
+	}.Encode())
+	if err != nil {
 		return nil, fmt.Errorf("TasksByProject: %w", err)
 	}
 
-	if cursor := tasksPaginated.NextCursor; cursor != nil {
-		return nil, fmt.Errorf("TasksByProject: got paginated results which we don't yet support: %s", *cursor)
-	}
-
-	tasks := tasksPaginated.Results // unfuck
-
 	// REST API results have no ordering, so we have to sort them.
 	sort.Slice(tasks, func(i, j int) bool {
 		return tasks[i].ChildOrder < tasks[j].ChildOrder
@@ -101,25 +181,152 @@ func (t *Client) TasksByProject(ctx context.Context, projectID string, now time.
 	return tasks, nil
 }
 
-func (t *Client) CreateTask(ctx context.Context, task Task) error {
-	if _, err := ezhttp.Post(ctx, "https://api.todoist.com/api/v1/tasks",
+func (t *Client) CreateTask(ctx context.Context, task Task) (*Task, error) {
+	if task.Labels == nil { // null is not accepted
+		task.Labels = []string{}
+	}
+
+	created := &Task{}
+	if _, err := ezhttp.Post(ctx, t.baseURL+"tasks",
 		ezhttp.AuthBearer(t.token),
+		ezhttp.Client(t.httpClient),
+		requestID(), // so the create is safe to retry
 		ezhttp.SendJSON(task),
+		ezhttp.RespondsJSONAllowUnknownFields(created),
 	); err != nil {
-		return fmt.Errorf("CreateTask: %w", err)
+		return nil, fmt.Errorf("CreateTask: %w", err)
 	}
 
-	return nil
+	t.mirror.update(created.ID, func(mirrored *Task) { *mirrored = *created })
+
+	return created, nil
 }
 
 func (t *Client) UpdateTask(ctx context.Context, task Task) error {
+	if task.Labels == nil { // null is not accepted
+		task.Labels = []string{}
+	}
+
 	// POST to update task, genius 👍
-	if _, err := ezhttp.Post(ctx, fmt.Sprintf("https://api.todoist.com/api/v1/tasks/%s", task.ID),
+	if _, err := ezhttp.Post(ctx, t.baseURL+"tasks/"+url.PathEscape(task.ID),
 		ezhttp.AuthBearer(t.token),
+		ezhttp.Client(t.httpClient),
+		requestID(),
 		ezhttp.SendJSON(task),
 	); err != nil {
 		return fmt.Errorf("UpdateTask: %w", err)
 	}
 
+	t.mirror.update(task.ID, func(mirrored *Task) {
+		mirrored.Content = task.Content
+		mirrored.Description = task.Description
+		mirrored.Labels = task.Labels
+	})
+
 	return nil
 }
+
+func (t *Client) MoveTask(ctx context.Context, taskID string, sectionID string) error {
+	if _, err := ezhttp.Post(ctx, t.baseURL+"tasks/"+url.PathEscape(taskID)+"/move",
+		ezhttp.AuthBearer(t.token),
+		ezhttp.Client(t.httpClient),
+		requestID(),
+		ezhttp.SendJSON(map[string]string{"section_id": sectionID}),
+	); err != nil {
+		return fmt.Errorf("MoveTask: %w", err)
+	}
+
+	t.mirror.update(taskID, func(mirrored *Task) { mirrored.SectionID = sectionID })
+
+	return nil
+}
+
+// marks the task completed
+func (t *Client) CloseTask(ctx context.Context, taskID string) error {
+	if _, err := ezhttp.Post(ctx, t.baseURL+"tasks/"+url.PathEscape(taskID)+"/close",
+		ezhttp.AuthBearer(t.token),
+		ezhttp.Client(t.httpClient),
+		requestID(),
+	); err != nil {
+		return fmt.Errorf("CloseTask: %w", err)
+	}
+
+	t.mirror.remove(taskID)
+
+	return nil
+}
+
+func (t *Client) DeleteTask(ctx context.Context, taskID string) error {
+	if _, err := ezhttp.Del(ctx, t.baseURL+"tasks/"+url.PathEscape(taskID),
+		ezhttp.AuthBearer(t.token),
+		ezhttp.Client(t.httpClient),
+	); err != nil {
+		return fmt.Errorf("DeleteTask: %w", err)
+	}
+
+	t.mirror.remove(taskID)
+
+	return nil
+}
+
+func (t *Client) SectionsByProject(ctx context.Context, projectID string) ([]Section, error) {
+	sections, err := getAllPages[Section](ctx, t, "sections?"+url.Values{
+		"project_id": {projectID},
+		"limit":      {"200"},
+	}.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("SectionsByProject: %w", err)
+	}
+
+	return sections, nil
+}
+
+func (t *Client) CreateSection(ctx context.Context, section Section) (*Section, error) {
+	created := &Section{}
+	if _, err := ezhttp.Post(ctx, t.baseURL+"sections",
+		ezhttp.AuthBearer(t.token),
+		ezhttp.Client(t.httpClient),
+		requestID(),
+		ezhttp.SendJSON(section),
+		ezhttp.RespondsJSONAllowUnknownFields(created),
+	); err != nil {
+		return nil, fmt.Errorf("CreateSection: %w", err)
+	}
+
+	return created, nil
+}
+
+// personal labels
+func (t *Client) Labels(ctx context.Context) ([]Label, error) {
+	labels, err := getAllPages[Label](ctx, t, "labels?limit=200")
+	if err != nil {
+		return nil, fmt.Errorf("Labels: %w", err)
+	}
+
+	return labels, nil
+}
+
+func (t *Client) CreateLabel(ctx context.Context, label Label) (*Label, error) {
+	created := &Label{}
+	if _, err := ezhttp.Post(ctx, t.baseURL+"labels",
+		ezhttp.AuthBearer(t.token),
+		ezhttp.Client(t.httpClient),
+		requestID(),
+		ezhttp.SendJSON(label),
+		ezhttp.RespondsJSONAllowUnknownFields(created),
+	); err != nil {
+		return nil, fmt.Errorf("CreateLabel: %w", err)
+	}
+
+	return created, nil
+}
+
+// Todoist de-duplicates requests with the same ID, which makes POSTs safe to retry
+func requestID() ezhttp.ConfigPiece {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+
+	return ezhttp.Header("X-Request-Id", hex.EncodeToString(id))
+}
@@ -0,0 +1,95 @@
+package todoist
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/function61/gokit/testing/assert"
+)
+
+// serves `pages` of tasks, linked with cursors "page2", "page3" etc.
+func testClient(t *testing.T, pages [][]string, cursorFor func(page int) string) *Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.URL.Query().Get("project_id"), "123")
+
+		page := 1
+		if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+			_, _ = fmt.Sscanf(cursor, "page%d", &page)
+		}
+
+		results := []string{}
+		for idx, content := range pages[page-1] {
+			results = append(results, fmt.Sprintf(`{"id": "%s", "content": "%s", "child_order": %d}`, content, content, page*10-idx))
+		}
+
+		nextCursor := "null"
+		if page < len(pages) {
+			nextCursor = `"` + cursorFor(page+1) + `"`
+		}
+
+		_, _ = fmt.Fprintf(w, `{"results": [%s], "next_cursor": %s}`, strings.Join(results, ","), nextCursor)
+	}))
+	t.Cleanup(srv.Close)
+
+	return &Client{token: "dummy", httpClient: srv.Client(), baseURL: srv.URL + "/"}
+}
+
+func TestTasksByProjectFollowsCursor(t *testing.T) {
+	todo := testClient(t, [][]string{{"milk", "bread"}, {"eggs"}, {}}, func(page int) string { return fmt.Sprintf("page%d", page) })
+
+	tasks, err := todo.TasksByProject(context.Background(), "123", time.Now())
+	assert.Ok(t, err)
+
+	contents := []string{}
+	for _, task := range tasks {
+		contents = append(contents, task.Content)
+	}
+	assert.Equal(t, strings.Join(contents, ","), "bread,milk,eggs") // sorted by order
+}
+
+func TestTasksByProjectCursorLoop(t *testing.T) {
+	todo := testClient(t, [][]string{{"milk"}, {"bread"}, {"eggs"}}, func(int) string { return "page2" })
+
+	_, err := todo.TasksByProject(context.Background(), "123", time.Now())
+	assert.Equal(t, err.Error(), "TasksByProject: got the same cursor twice: page2")
+}
+
+func TestTasksByProjectCanceled(t *testing.T) {
+	todo := testClient(t, [][]string{{"milk"}}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := todo.TasksByProject(ctx, "123", time.Now())
+	assert.Equal(t, err.Error(), "TasksByProject: context canceled")
+}
+
+func TestCreateSectionAndMoveTask(t *testing.T) {
+	requests := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+string(body))
+
+		if r.URL.Path == "/sections" {
+			_, _ = w.Write([]byte(`{"id": "s1", "project_id": "123", "name": "🥕 Produce", "section_order": 2}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	todo := &Client{token: "dummy", httpClient: srv.Client(), baseURL: srv.URL + "/"}
+
+	section, err := todo.CreateSection(context.Background(), Section{ProjectID: "123", Name: "🥕 Produce", Order: 2})
+	assert.Ok(t, err)
+	assert.Equal(t, section.ID, "s1")
+
+	assert.Ok(t, todo.MoveTask(context.Background(), "t1", "s1"))
+
+	assert.Equal(t, strings.Join(requests, "\n"), `POST /sections {"project_id":"123","name":"🥕 Produce","section_order":2}
+POST /tasks/t1/move {"section_id":"s1"}`)
+}
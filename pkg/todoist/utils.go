@@ -3,10 +3,12 @@ package todoist
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
-// only date component in format: 2006-01-02
+// only date component in format: 2006-01-02. due dates with time (like "2006-01-02T15:04:05" or
+// "2006-01-02T15:04:05Z") are truncated to their date.
 type JSONPlainDate struct {
 	time.Time
 }
@@ -14,7 +16,14 @@ type JSONPlainDate struct {
 var _ json.Unmarshaler = (*JSONPlainDate)(nil)
 
 func (b *JSONPlainDate) UnmarshalJSON(input []byte) error {
-	parsed, err := time.Parse(`"2006-01-02"`, string(input))
+	var str string
+	if err := json.Unmarshal(input, &str); err != nil {
+		return fmt.Errorf("JSONPlainDate: %w", err)
+	}
+
+	date, _, _ := strings.Cut(str, "T")
+
+	parsed, err := time.Parse("2006-01-02", date)
 	if err != nil {
 		return fmt.Errorf("JSONPlainDate: %w", err)
 	}
@@ -0,0 +1,67 @@
+package todoist
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// https://developer.todoist.com/api/v1/#tag/Webhooks
+
+const (
+	WebhookEventItemCompleted = "item:completed"
+	WebhookEventItemDeleted   = "item:deleted"
+)
+
+var ErrWebhookSignature = errors.New("invalid webhook signature")
+
+type WebhookEvent struct {
+	EventName   string           `json:"event_name"` // WebhookEventItemCompleted | ...
+	UserID      string           `json:"user_id"`
+	EventData   WebhookEventItem `json:"event_data"`
+	TriggeredAt string           `json:"triggered_at"`
+}
+
+// item as seen in webhook events. not `Task` since some fields are differently typed here.
+type WebhookEventItem struct {
+	ID          string `json:"id"`
+	ProjectID   string `json:"project_id"`
+	Content     string `json:"content"`
+	Description string `json:"description"`
+}
+
+// verifies the request's signature (made with the app's client secret) and parses the event
+func ParseWebhook(r *http.Request, clientSecret string) (*WebhookEvent, error) {
+	withErr := func(err error) (*WebhookEvent, error) { return nil, fmt.Errorf("ParseWebhook: %w", err) }
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1024*1024))
+	if err != nil {
+		return withErr(err)
+	}
+
+	if !validWebhookSignature(body, r.Header.Get("X-Todoist-Hmac-SHA256"), clientSecret) {
+		return withErr(ErrWebhookSignature)
+	}
+
+	event := &WebhookEvent{}
+	if err := json.Unmarshal(body, event); err != nil {
+		return withErr(err)
+	}
+
+	return event, nil
+}
+
+// signature is base64(HMAC-SHA256(body))
+func validWebhookSignature(body []byte, signature string, clientSecret string) bool {
+	mac := hmac.New(sha256.New, []byte(clientSecret))
+	_, _ = mac.Write(body)
+
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,33 @@
+package todoist
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/function61/gokit/testing/assert"
+)
+
+func TestParseWebhook(t *testing.T) {
+	body := `{"event_name": "item:completed", "user_id": "1", "event_data": {"id": "42", "project_id": "123", "content": "Milk", "description": "[Details](https://example.com/item/123)", "completed_at": "2025-10-01T12:00:00Z"}}`
+
+	parse := func(signature string) (*WebhookEvent, error) {
+		req := httptest.NewRequest("POST", "/webhook", strings.NewReader(body))
+		req.Header.Set("X-Todoist-Hmac-SHA256", signature)
+		return ParseWebhook(req, "secret")
+	}
+
+	// echo -n "$body" | openssl dgst -sha256 -hmac secret -binary | base64
+	event, err := parse("YMYe00yLOSoOYmo4uEfwjq4Gj+yJ2CR0nlMiza1Djnk=")
+	assert.Ok(t, err)
+	assert.Equal(t, event.EventName, WebhookEventItemCompleted)
+	assert.Equal(t, event.EventData.ID, "42")
+	assert.Equal(t, event.EventData.Content, "Milk")
+
+	_, err = parse("YMYe00yLOSoOYmo4uEfwjq4Gj+yJ2CR0nlMiza1Djnz=")
+	assert.Equal(t, errors.Is(err, ErrWebhookSignature), true)
+
+	_, err = parse("")
+	assert.Equal(t, errors.Is(err, ErrWebhookSignature), true)
+}
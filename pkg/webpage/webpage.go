@@ -0,0 +1,65 @@
+// Fetches web pages of arbitrary sites (recipes, product pages, product images) so the page can be
+// fetched once and then given to different parsers.
+package webpage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/function61/gokit/net/http/ezhttp"
+	"github.com/joonas-fi/shopping-list-manager/pkg/resilienthttp"
+)
+
+const (
+	MaxSize = 5 * 1024 * 1024
+
+	// some sites refuse to serve Go's default user agent
+	UserAgent = "Mozilla/5.0 (compatible; shopping-list-manager; +https://github.com/joonas-fi/shopping-list-manager)"
+)
+
+// the sites are arbitrary, so one site being down must not open the circuit for all of them
+var httpClient = func() *http.Client {
+	opts := resilienthttp.DefaultOptions()
+	opts.BreakerThreshold = 0
+	return resilienthttp.New("webpage", opts)
+}()
+
+type Page struct {
+	URL        string // after redirects
+	StatusCode int
+	Status     string // like "404 Not Found"
+	Body       []byte // at most `MaxSize` bytes
+}
+
+func (p Page) OK() bool {
+	return p.StatusCode >= 200 && p.StatusCode <= 299
+}
+
+// follows redirects (like ones of URL shorteners). non-2xx response is not an error, since where the
+// redirects lead to can be useful on its own (see `Page.OK()`).
+func Fetch(ctx context.Context, link string) (*Page, error) {
+	withErr := func(err error) (*Page, error) { return nil, fmt.Errorf("webpage.Fetch: %w", err) }
+
+	res, err := ezhttp.Get(ctx, link,
+		ezhttp.Client(httpClient),
+		ezhttp.Header("User-Agent", UserAgent),
+		ezhttp.TolerateNon2xxResponse)
+	if err != nil {
+		return withErr(err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, MaxSize))
+	if err != nil {
+		return withErr(err)
+	}
+
+	return &Page{
+		URL:        res.Request.URL.String(),
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		Body:       body,
+	}, nil
+}
@@ -0,0 +1,71 @@
+package websearch
+
+// Bing Web Search API: https://learn.microsoft.com/en-us/bing/search-apis/bing-web-search/reference/endpoints
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/function61/gokit/net/http/ezhttp"
+	"github.com/joonas-fi/shopping-list-manager/pkg/resilienthttp"
+)
+
+type bing struct {
+	apiKey     string
+	baseURL    string // overridable for tests
+	httpClient *http.Client
+}
+
+func NewBing(apiKey string) Searcher {
+	return newBing(apiKey, "https://api.bing.microsoft.com/v7.0/search")
+}
+
+func newBing(apiKey string, baseURL string) *bing {
+	return &bing{
+		apiKey:     apiKey,
+		baseURL:    baseURL,
+		httpClient: resilienthttp.New("bing", searchHTTPOptions()),
+	}
+}
+
+func (b *bing) Search(ctx context.Context, query string) ([]Result, error) {
+	withErr := func(err error) ([]Result, error) { return nil, fmt.Errorf("bing.Search: %w", err) }
+
+	queryParams := url.Values{
+		"q": {query},
+	}
+
+	res := &bingResponse{}
+	if _, err := ezhttp.Get(ctx, b.baseURL+"?"+queryParams.Encode(),
+		ezhttp.Header("Ocp-Apim-Subscription-Key", b.apiKey),
+		ezhttp.Client(b.httpClient),
+		ezhttp.RespondsJSONAllowUnknownFields(res),
+	); err != nil {
+		return withErr(err)
+	}
+
+	results := []Result{}
+	for _, item := range res.WebPages.Value {
+		results = append(results, Result{
+			Title:   item.Name,
+			Snippet: item.Snippet,
+			Link:    item.URL,
+			Image:   item.ThumbnailURL,
+		})
+	}
+
+	return results, nil
+}
+
+type bingResponse struct {
+	WebPages struct {
+		Value []struct {
+			Name         string `json:"name"`
+			URL          string `json:"url"`
+			Snippet      string `json:"snippet"`
+			ThumbnailURL string `json:"thumbnailUrl"`
+		} `json:"value"`
+	} `json:"webPages"`
+}
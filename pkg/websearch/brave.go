@@ -0,0 +1,96 @@
+package websearch
+
+// Brave Search API: https://api-dashboard.search.brave.com/app/documentation/web-search/get-started
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/function61/gokit/net/http/ezhttp"
+	"github.com/joonas-fi/shopping-list-manager/pkg/resilienthttp"
+)
+
+type brave struct {
+	apiKey     string
+	baseURL    string // overridable for tests
+	httpClient *http.Client
+}
+
+func NewBrave(apiKey string) Searcher {
+	return newBrave(apiKey, "https://api.search.brave.com/res/v1/web/search")
+}
+
+func newBrave(apiKey string, baseURL string) *brave {
+	return &brave{
+		apiKey:     apiKey,
+		baseURL:    baseURL,
+		httpClient: resilienthttp.New("brave", searchHTTPOptions()),
+	}
+}
+
+func (b *brave) Search(ctx context.Context, query string) ([]Result, error) {
+	withErr := func(err error) ([]Result, error) { return nil, fmt.Errorf("brave.Search: %w", err) }
+
+	queryParams := url.Values{
+		"q": {query},
+	}
+
+	res := &braveResponse{}
+	if _, err := ezhttp.Get(ctx, b.baseURL+"?"+queryParams.Encode(),
+		ezhttp.Header("Accept", "application/json"),
+		ezhttp.Header("X-Subscription-Token", b.apiKey),
+		ezhttp.Client(b.httpClient),
+		ezhttp.RespondsJSONAllowUnknownFields(res),
+	); err != nil {
+		return withErr(err)
+	}
+
+	results := []Result{}
+	for _, item := range res.Web.Results {
+		result := Result{
+			Title:   item.Title,
+			Snippet: item.Description,
+			Link:    item.URL,
+		}
+
+		if item.Thumbnail != nil {
+			result.Image = item.Thumbnail.Src
+		}
+
+		if p := item.Product; p != nil {
+			result.Product = &Product{
+				Name:        p.Name,
+				Description: p.Description,
+			}
+			if p.Thumbnail != nil {
+				result.Product.Image = p.Thumbnail.Src
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+type braveThumbnail struct {
+	Src string `json:"src"`
+}
+
+type braveResponse struct {
+	Web struct {
+		Results []struct {
+			Title       string          `json:"title"`
+			URL         string          `json:"url"`
+			Description string          `json:"description"`
+			Thumbnail   *braveThumbnail `json:"thumbnail"`
+			Product     *struct {
+				Name        string          `json:"name"`
+				Description string          `json:"description"`
+				Thumbnail   *braveThumbnail `json:"thumbnail"`
+			} `json:"product"`
+		} `json:"results"`
+	} `json:"web"`
+}
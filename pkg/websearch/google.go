@@ -0,0 +1,95 @@
+package websearch
+
+import (
+	"context"
+
+	"github.com/joonas-fi/shopping-list-manager/pkg/googlesearch"
+)
+
+type google struct {
+	client interface {
+		Search(ctx context.Context, query string) (*googlesearch.CustomSearch, error)
+	}
+}
+
+// Google custom search. configuration is read from ENV (see `googlesearch.New()`)
+func NewGoogle() (Searcher, error) {
+	client, err := googlesearch.New()
+	if err != nil {
+		return nil, err
+	}
+
+	return &google{client}, nil
+}
+
+func (g *google) Search(ctx context.Context, query string) ([]Result, error) {
+	cs, err := g.client.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return FromGoogle(cs.Items), nil
+}
+
+func FromGoogle(items []googlesearch.Item) []Result {
+	results := []Result{}
+	for _, item := range items {
+		results = append(results, fromGoogleItem(item))
+	}
+	return results
+}
+
+func fromGoogleItem(item googlesearch.Item) Result {
+	result := Result{
+		Title:   item.Title,
+		Snippet: item.Snippet,
+		Link:    item.Link,
+	}
+
+	pm := item.Pagemap
+
+	if len(pm.CSEImage) > 0 {
+		result.Image = pm.CSEImage[0].Src
+	}
+
+	product := Product{}
+
+	// prefer schema.org product, then hproduct microformat, then OpenGraph
+	if len(pm.Product) > 0 {
+		product.Name = pm.Product[0].Name
+		product.Description = pm.Product[0].Description
+		product.Image = pm.Product[0].Image
+		product.Brand = pm.Product[0].Brand
+	}
+	if len(pm.HProduct) > 0 {
+		if product.Name == "" {
+			product.Name = pm.HProduct[0].Fn
+		}
+		if product.Description == "" {
+			product.Description = pm.HProduct[0].Description
+		}
+		if product.Image == "" {
+			product.Image = pm.HProduct[0].Photo
+		}
+	}
+	if len(pm.Metatags) > 0 && pm.Metatags[0].OgType == "product" {
+		if product.Name == "" {
+			product.Name = pm.Metatags[0].OgTitle
+		}
+		if product.Description == "" {
+			product.Description = pm.Metatags[0].OgDescription
+		}
+		if product.Image == "" {
+			product.Image = pm.Metatags[0].OgImage
+		}
+		if product.Brand == "" {
+			product.Brand = pm.Metatags[0].ProductBrand
+		}
+	}
+
+	if product != (Product{}) {
+		result.Product = &product
+	}
+
+	return result
+}
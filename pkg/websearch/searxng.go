@@ -0,0 +1,70 @@
+package websearch
+
+// SearXNG is a self-hostable metasearch engine: https://docs.searxng.org/dev/search_api.html
+//
+// NOTE: JSON output needs to be enabled in SearXNG's settings (`search.formats` must contain `json`).
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/function61/gokit/net/http/ezhttp"
+	"github.com/joonas-fi/shopping-list-manager/pkg/resilienthttp"
+)
+
+type searxng struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// baseURL looks like "https://searxng.example.com/"
+func NewSearXNG(baseURL string) Searcher {
+	return &searxng{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: resilienthttp.New("searxng", searchHTTPOptions()),
+	}
+}
+
+func (s *searxng) Search(ctx context.Context, query string) ([]Result, error) {
+	withErr := func(err error) ([]Result, error) { return nil, fmt.Errorf("searxng.Search: %w", err) }
+
+	queryParams := url.Values{
+		"q":      {query},
+		"format": {"json"},
+	}
+
+	res := &searxngResponse{}
+	if _, err := ezhttp.Get(ctx, s.baseURL+"/search?"+queryParams.Encode(),
+		ezhttp.Client(s.httpClient),
+		ezhttp.RespondsJSONAllowUnknownFields(res),
+	); err != nil {
+		return withErr(err)
+	}
+
+	results := []Result{}
+	for _, item := range res.Results {
+		results = append(results, Result{
+			Title:   item.Title,
+			Snippet: item.Content,
+			Link:    item.URL,
+			Image:   cmp.Or(item.ImgSrc, item.Thumbnail),
+		})
+	}
+
+	return results, nil
+}
+
+type searxngResponse struct {
+	Query   string `json:"query"`
+	Results []struct {
+		Title     string `json:"title"`
+		URL       string `json:"url"`
+		Content   string `json:"content"`
+		ImgSrc    string `json:"img_src"`
+		Thumbnail string `json:"thumbnail"`
+	} `json:"results"`
+}
@@ -0,0 +1,79 @@
+// Provider-agnostic web search. Barcodes are resolved by searching the web, and each search
+// backend (Google, SearXNG, Brave, Bing) has its own result format, so results are normalized
+// into a common type here.
+package websearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joonas-fi/shopping-list-manager/pkg/resilienthttp"
+)
+
+type Searcher interface {
+	Search(ctx context.Context, query string) ([]Result, error)
+}
+
+type Result struct {
+	Title   string   `json:"title"`
+	Snippet string   `json:"snippet,omitempty"`
+	Link    string   `json:"link"`
+	Image   string   `json:"image,omitempty"`   // URL to an image that represents the result (if known)
+	Product *Product `json:"product,omitempty"` // structured product data (if the search engine extracted any)
+}
+
+// structured product data the search engines extract from pages (schema.org, OpenGraph etc.)
+type Product struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Brand       string `json:"brand,omitempty"` // not all search engines extract this
+	Image       string `json:"image,omitempty"`
+}
+
+// same as Google's (see `googlesearch.New()`)
+func searchHTTPOptions() resilienthttp.Options {
+	opts := resilienthttp.DefaultOptions()
+	opts.Timeout = 10 * time.Second
+	return opts
+}
+
+// tries each searcher in order, moving on to next if the previous one failed or returned no results
+func Fallback(searchers ...Searcher) Searcher {
+	return &fallback{searchers}
+}
+
+type fallback struct {
+	searchers []Searcher
+}
+
+func (f *fallback) Search(ctx context.Context, query string) ([]Result, error) {
+	if len(f.searchers) == 0 {
+		return nil, errors.New("websearch.Fallback: no searchers configured")
+	}
+
+	errs := []error{}
+	anySucceeded := false
+
+	for idx, searcher := range f.searchers {
+		results, err := searcher.Search(ctx, query)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("searcher %d: %w", idx, err))
+			continue
+		}
+
+		if len(results) > 0 {
+			return results, nil
+		}
+
+		// no results is not an error, but next searcher might know better
+		anySucceeded = true
+	}
+
+	if anySucceeded {
+		return []Result{}, nil
+	}
+
+	return nil, errors.Join(errs...)
+}
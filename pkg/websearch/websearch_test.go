@@ -0,0 +1,153 @@
+package websearch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/function61/gokit/net/http/ezhttp"
+	"github.com/function61/gokit/testing/assert"
+	"github.com/joonas-fi/shopping-list-manager/pkg/googlesearch"
+)
+
+func TestFallback(t *testing.T) {
+	ctx := context.Background()
+
+	failing := staticSearcher{err: errors.New("quota exceeded")}
+	empty := staticSearcher{}
+	found := staticSearcher{results: []Result{{Title: "Tacokastike 100 g"}}}
+
+	search := func(searchers ...Searcher) string {
+		results, err := Fallback(searchers...).Search(ctx, "123")
+		if err != nil {
+			return err.Error()
+		}
+		if len(results) == 0 {
+			return "no results"
+		}
+		return results[0].Title
+	}
+
+	assert.Equal(t, search(failing, found), "Tacokastike 100 g")
+	assert.Equal(t, search(empty, found), "Tacokastike 100 g")
+	assert.Equal(t, search(failing, empty), "no results")
+	assert.Equal(t, search(failing), "searcher 0: quota exceeded")
+}
+
+func TestSearXNG(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.URL.Path, "/search")
+		assert.Equal(t, r.URL.Query().Get("format"), "json")
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"query": "6408180733659", "results": [{"title": "Vaasan Kaurasämpylä 480 g", "url": "https://example.com/p", "content": "Kaurainen sämpylä", "thumbnail": "https://example.com/p.jpg"}]}`))
+	}))
+	defer srv.Close()
+
+	results, err := NewSearXNG(srv.URL+"/").Search(context.Background(), "6408180733659")
+	assert.Ok(t, err)
+
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].Title, "Vaasan Kaurasämpylä 480 g")
+	assert.Equal(t, results[0].Snippet, "Kaurainen sämpylä")
+	assert.Equal(t, results[0].Link, "https://example.com/p")
+	assert.Equal(t, results[0].Image, "https://example.com/p.jpg")
+}
+
+func TestBrave(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.URL.Query().Get("q"), "6408180733659")
+		assert.Equal(t, r.Header.Get("X-Subscription-Token"), "secret")
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"web": {"results": [
+			{"title": "Vaasan Kaurasämpylä 480 g", "url": "https://example.com/p", "description": "Kaurainen sämpylä", "thumbnail": {"src": "https://example.com/p.jpg"},
+			 "product": {"name": "Kaurasämpylä", "description": "6 kpl", "thumbnail": {"src": "https://example.com/product.jpg"}}},
+			{"title": "Other", "url": "https://example.com/other", "description": ""}
+		]}}`))
+	}))
+	defer srv.Close()
+
+	results, err := newBrave("secret", srv.URL).Search(context.Background(), "6408180733659")
+	assert.Ok(t, err)
+
+	assert.Equal(t, len(results), 2)
+	assert.Equal(t, results[0].Title, "Vaasan Kaurasämpylä 480 g")
+	assert.Equal(t, results[0].Snippet, "Kaurainen sämpylä")
+	assert.Equal(t, results[0].Link, "https://example.com/p")
+	assert.Equal(t, results[0].Image, "https://example.com/p.jpg")
+	assert.Equal(t, *results[0].Product, Product{Name: "Kaurasämpylä", Description: "6 kpl", Image: "https://example.com/product.jpg"})
+	assert.Equal(t, results[1].Image, "")
+	assert.Equal(t, results[1].Product == nil, true)
+}
+
+func TestBing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.URL.Query().Get("q"), "6408180733659")
+		assert.Equal(t, r.Header.Get("Ocp-Apim-Subscription-Key"), "secret")
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"webPages": {"value": [{"name": "Vaasan Kaurasämpylä 480 g", "url": "https://example.com/p", "snippet": "Kaurainen sämpylä", "thumbnailUrl": "https://example.com/p.jpg"}]}}`))
+	}))
+	defer srv.Close()
+
+	results, err := newBing("secret", srv.URL).Search(context.Background(), "6408180733659")
+	assert.Ok(t, err)
+
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].Title, "Vaasan Kaurasämpylä 480 g")
+	assert.Equal(t, results[0].Snippet, "Kaurainen sämpylä")
+	assert.Equal(t, results[0].Link, "https://example.com/p")
+	assert.Equal(t, results[0].Image, "https://example.com/p.jpg")
+	assert.Equal(t, results[0].Product == nil, true)
+}
+
+func TestGoogle(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"items": [
+			{"title": "Vaasan Kaurasämpylä 480 g", "link": "https://example.com/p", "snippet": "Kaurainen sämpylä", "pagemap": {
+				"cse_image": [{"src": "https://example.com/p.jpg"}],
+				"product": [{"name": "Kaurasämpylä", "brand": "Vaasan"}],
+				"hproduct": [{"fn": "ignored", "description": "6 kpl"}],
+				"metatags": [{"og:type": "product", "og:image": "https://example.com/og.jpg", "product:brand": "ignored"}]
+			}},
+			{"title": "Valio kevytmaito", "link": "https://example.com/maito", "pagemap": {
+				"metatags": [{"og:type": "product", "og:title": "Kevytmaito 1 l", "product:brand": "Valio"}]
+			}},
+			{"title": "Blog post", "link": "https://example.com/blog", "pagemap": {"metatags": [{"og:type": "article", "og:title": "ignored"}]}}
+		]}`))
+	}))
+	defer srv.Close()
+
+	results, err := (&google{googleFake(srv.URL)}).Search(context.Background(), "6408180733659")
+	assert.Ok(t, err)
+
+	assert.Equal(t, len(results), 3)
+	assert.Equal(t, results[0].Title, "Vaasan Kaurasämpylä 480 g")
+	assert.Equal(t, results[0].Snippet, "Kaurainen sämpylä")
+	assert.Equal(t, results[0].Image, "https://example.com/p.jpg")
+	assert.Equal(t, *results[0].Product, Product{Name: "Kaurasämpylä", Description: "6 kpl", Brand: "Vaasan", Image: "https://example.com/og.jpg"})
+	assert.Equal(t, *results[1].Product, Product{Name: "Kevytmaito 1 l", Brand: "Valio"})
+	assert.Equal(t, results[2].Product == nil, true)
+}
+
+// talks to a fake custom search API
+type googleFake string
+
+func (g googleFake) Search(ctx context.Context, _ string) (*googlesearch.CustomSearch, error) {
+	cs := &googlesearch.CustomSearch{}
+	_, err := ezhttp.Get(ctx, string(g), ezhttp.RespondsJSONAllowUnknownFields(cs))
+	return cs, err
+}
+
+type staticSearcher struct {
+	results []Result
+	err     error
+}
+
+func (s staticSearcher) Search(_ context.Context, _ string) ([]Result, error) {
+	return s.results, s.err
+}